@@ -42,21 +42,20 @@ var (
 
 type AddTopicPrompt struct {
 	focusIndex int
-	inputs     []textinput.Model
+	inputs     [3]textinput.Model
 	cursorMode textinput.CursorMode
 	logger     *log.Logger
 }
 
 func InitialAddTopicPrompt(log *log.Logger) AddTopicPrompt {
 	m := AddTopicPrompt{
-		inputs: make([]textinput.Model, 3),
 		logger: log,
 	}
 
 	var t textinput.Model
 
 	for i := range m.inputs {
-		t = textinput.New()
+		t = newTextInput()
 		t.CursorStyle = cursorStyle
 		t.CharLimit = 32
 
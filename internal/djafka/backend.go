@@ -0,0 +1,64 @@
+package djafka
+
+import (
+	"context"
+	"log"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// KafkaBackend is everything the TUI needs from a cluster. Service implements
+// it against a real broker, FakeCluster in memory.
+type KafkaBackend interface {
+	ListTopics(ctx context.Context) ([]Topic, error)
+	CreateTopic(ctx context.Context, name string, partitions int, replicationFactor int) (Topic, error)
+	GetTopicConfig(ctx context.Context, name string) (TopicConfig, error)
+	GetTopicMetadata(ctx context.Context, topic string) (kafka.TopicMetadata, error)
+	ListConsumerGroups(ctx context.Context) ([]string, error)
+	ListConsumers(ctx context.Context, groupIds []string) ([]Consumer, error)
+	ResetConsumerOffsets(ctx context.Context, group string, topic string, offset int64) error
+	// Produce sends record to its topic and returns it as delivered. The
+	// partition may be kafka.PartitionAny, the offset is ignored.
+	Produce(ctx context.Context, record Record) (Record, error)
+	// FetchMessages sends the records of topic from start on to records,
+	// including those produced later, until ctx is done.
+	FetchMessages(ctx context.Context, topic string, start StartPosition, records chan<- Record) error
+	// ReadRange sends the records of the given partitions of topic, or of all
+	// partitions if none are given, from start up to, not including, end to
+	// records and returns once all were sent.
+	ReadRange(ctx context.Context, topic string, partitions []int32, start StartPosition, end StartPosition, records chan<- Record) error
+	// Health fetches the brokers and the controller of the cluster and
+	// measures the round trip time.
+	Health(ctx context.Context) ClusterHealth
+	// Close cancels all running requests and releases the connection.
+	Close()
+}
+
+var _ KafkaBackend = (*Service)(nil)
+
+// BackendFactory connects to the cluster of a connection.
+type BackendFactory func(conn Connection, logger *log.Logger) (KafkaBackend, error)
+
+// ConnectService is the BackendFactory connecting to real brokers.
+func ConnectService(conn Connection, logger *log.Logger) (KafkaBackend, error) {
+	service, err := NewService(conn, logger)
+	if err != nil {
+		// a nil *Service must not end up in a non-nil interface
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// CheckConnection connects to the cluster of conn and fetches its topics to
+// verify the connection settings.
+func CheckConnection(ctx context.Context, connect BackendFactory, conn Connection, logger *log.Logger) error {
+	backend, err := connect(conn, logger)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	_, err = backend.ListTopics(ctx)
+	return err
+}
@@ -0,0 +1,567 @@
+package djafka
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// BulkFormat is the format of a file of records to produce.
+type BulkFormat string
+
+const (
+	// BulkJSONLines has a JSON object per line with the fields key, value,
+	// headers, partition, timestamp and encoding, all of them optional
+	BulkJSONLines BulkFormat = "jsonl"
+	// BulkCSV has a header row naming the columns key, value, partition,
+	// timestamp, headers and header.<name>
+	BulkCSV BulkFormat = "csv"
+	// BulkText has a value per line
+	BulkText BulkFormat = "text"
+)
+
+const (
+	// bulkWorkers is the number of records sent at the same time
+	bulkWorkers = 8
+	// maxLineSize is the size of the largest line of a file to produce
+	maxLineSize = 16 * 1024 * 1024
+)
+
+// ParseBulkFormat returns the format named by format, or the format of path
+// by its extension if format is empty.
+func ParseBulkFormat(format string, path string) (BulkFormat, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jsonl", ".ndjson":
+			return BulkJSONLines, nil
+		case ".csv":
+			return BulkCSV, nil
+		default:
+			return BulkText, nil
+		}
+	}
+
+	switch BulkFormat(strings.ToLower(format)) {
+	case BulkJSONLines, "json":
+		return BulkJSONLines, nil
+	case BulkCSV:
+		return BulkCSV, nil
+	case BulkText, "txt":
+		return BulkText, nil
+	}
+
+	return "", fmt.Errorf("Unknown format '%s', expected jsonl, csv or text.", format)
+}
+
+// ScanRecordsFile calls fn with every record to produce to topic of the file at
+// path as it is read, so the records are never held in memory all at once.
+// Blank lines are skipped. It stops at the first error, including those of fn.
+func ScanRecordsFile(path string, format BulkFormat, topic string, fn func(Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Failed to open file '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	if err := ScanRecords(f, format, topic, fn); err != nil {
+		return fmt.Errorf("Failed to read file '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// ScanRecords calls fn with every record to produce to topic read from r.
+func ScanRecords(r io.Reader, format BulkFormat, topic string, fn func(Record) error) error {
+	switch format {
+	case BulkJSONLines:
+		return scanJSONLines(r, topic, fn)
+	case BulkCSV:
+		return scanCSV(r, topic, fn)
+	default:
+		return scanText(r, topic, fn)
+	}
+}
+
+// ReadRecords reads all records to produce to topic from r.
+func ReadRecords(r io.Reader, format BulkFormat, topic string) ([]Record, error) {
+	records := []Record{}
+	err := ScanRecords(r, format, topic, func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return scanner
+}
+
+func scanText(r io.Reader, topic string, fn func(Record) error) error {
+	scanner := newLineScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := fn(Record{Topic: topic, Partition: kafka.PartitionAny, Value: []byte(line)}); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// jsonRecord is a line of a JSON Lines file. Keys and values which are JSON
+// strings are produced unquoted, any other JSON as is. With the base64
+// encoding, keys, values and header values are base64 strings instead, as
+// written by the binary export.
+type jsonRecord struct {
+	Key       json.RawMessage `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	Headers   json.RawMessage `json:"headers"`
+	Partition *int32          `json:"partition"`
+	// Timestamp is either milliseconds since the epoch or a time
+	Timestamp json.RawMessage `json:"timestamp"`
+	Encoding  string          `json:"encoding"`
+}
+
+const base64Encoding = "base64"
+
+func scanJSONLines(r io.Reader, topic string, fn func(Record) error) error {
+	scanner := newLineScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		record, err := parseJSONRecord(scanner.Bytes(), topic)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func parseJSONRecord(line []byte, topic string) (Record, error) {
+	var raw jsonRecord
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return Record{}, err
+	}
+
+	decode := jsonBytes
+	switch raw.Encoding {
+	case "":
+	case base64Encoding:
+		decode = base64Bytes
+	default:
+		return Record{}, fmt.Errorf("unknown encoding '%s'", raw.Encoding)
+	}
+
+	record := Record{Topic: topic, Partition: kafka.PartitionAny}
+	var err error
+	if record.Key, err = decode(raw.Key); err != nil {
+		return record, fmt.Errorf("key: %w", err)
+	}
+	if record.Value, err = decode(raw.Value); err != nil {
+		return record, fmt.Errorf("value: %w", err)
+	}
+	if record.Headers, err = jsonHeaders(raw.Headers, decode); err != nil {
+		return record, fmt.Errorf("headers: %w", err)
+	}
+	if raw.Partition != nil {
+		if *raw.Partition < 0 {
+			return record, fmt.Errorf("partition %d is negative", *raw.Partition)
+		}
+		record.Partition = *raw.Partition
+	}
+	if record.Timestamp, err = jsonTimestamp(raw.Timestamp); err != nil {
+		return record, fmt.Errorf("timestamp: %w", err)
+	}
+
+	return record, nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// jsonBytes returns a JSON string unquoted and any other JSON compacted.
+func jsonBytes(raw json.RawMessage) ([]byte, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// base64Bytes decodes a base64 JSON string.
+func base64Bytes(raw json.RawMessage) ([]byte, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// jsonHeaders reads headers either from an object, sorted by name, or from a
+// list of objects with a key and a value, which keeps their order and allows
+// repeated names. Header values are decoded by decode.
+func jsonHeaders(raw json.RawMessage, decode func(json.RawMessage) ([]byte, error)) ([]kafka.Header, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+
+	if raw[0] == '[' {
+		var list []struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		headers := make([]kafka.Header, 0, len(list))
+		for _, item := range list {
+			value, err := decode(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			headers = append(headers, kafka.Header{Key: item.Key, Value: value})
+		}
+		return headers, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]kafka.Header, 0, len(object))
+	for _, name := range names {
+		value, err := decode(object[name])
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, kafka.Header{Key: name, Value: value})
+	}
+	return headers, nil
+}
+
+func jsonTimestamp(raw json.RawMessage) (time.Time, error) {
+	if isNull(raw) {
+		return time.Time{}, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return time.Time{}, err
+		}
+		return parseRecordTime(s)
+	}
+
+	var ms int64
+	if err := json.Unmarshal(raw, &ms); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// parseRecordTime parses the timestamp of a record given as milliseconds
+// since the epoch or as a time. An empty timestamp is left to the producer.
+func parseRecordTime(input string) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(input, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	if timestamp, ok := parseTime(input); ok {
+		return timestamp, nil
+	}
+
+	return time.Time{}, fmt.Errorf("'%s' is neither milliseconds since the epoch nor a time like '%s'", input, timestampLayout)
+}
+
+const csvHeaderPrefix = "header."
+
+func scanCSV(r io.Reader, topic string, fn func(Record) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 0
+
+	columns, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		switch column {
+		case "key", "value", "partition", "timestamp", "headers", "topic", "offset":
+		default:
+			if !strings.HasPrefix(column, csvHeaderPrefix) || column == csvHeaderPrefix {
+				return fmt.Errorf("unknown column '%s', expected key, value, partition, timestamp, headers or %s<name>", column, csvHeaderPrefix)
+			}
+		}
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+
+		record := Record{Topic: topic, Partition: kafka.PartitionAny}
+		for i, cell := range row {
+			switch column := columns[i]; column {
+			case "key":
+				if cell != "" {
+					record.Key = []byte(cell)
+				}
+			case "value":
+				record.Value = []byte(cell)
+			case "partition":
+				if strings.TrimSpace(cell) == "" {
+					continue
+				}
+				p, err := strconv.ParseInt(strings.TrimSpace(cell), 10, 32)
+				if err != nil || p < 0 {
+					return fmt.Errorf("line %d: '%s' is not a valid partition", line, cell)
+				}
+				record.Partition = int32(p)
+			case "timestamp":
+				if record.Timestamp, err = parseRecordTime(cell); err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+			case "headers":
+				headers, err := parseHeaders(cell)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+				record.Headers = append(record.Headers, headers...)
+			case "topic", "offset":
+				// written by the export, the records go to the chosen topic
+			default:
+				if cell != "" {
+					record.Headers = append(record.Headers, kafka.Header{Key: strings.TrimPrefix(column, csvHeaderPrefix), Value: []byte(cell)})
+				}
+			}
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// BulkProgress counts the delivered and failed records of a bulk produce.
+type BulkProgress struct {
+	Total  int
+	Sent   int
+	Failed int
+	// Err is the reason of the last failure
+	Err  error
+	Done bool
+}
+
+// Percent returns the share of records already handled.
+func (p BulkProgress) Percent() float64 {
+	if p.Total == 0 {
+		return 1
+	}
+	return float64(p.Sent+p.Failed) / float64(p.Total)
+}
+
+func (p BulkProgress) String() string {
+	s := fmt.Sprintf("%d/%d sent · %d failed", p.Sent, p.Total, p.Failed)
+	if p.Err != nil {
+		s = fmt.Sprintf("%s · last error: %s", s, p.Err)
+	}
+	return s
+}
+
+// ProduceRecords sends records through backend with at most rate records per
+// second, or as fast as possible if rate is 0. Records with the same partition
+// and key are sent one after another in the given order. progress is called
+// with the counts after every record and once more when all are done; it must
+// not block. Records not sent because ctx is done count as failed.
+func ProduceRecords(ctx context.Context, backend KafkaBackend, records []Record, rate int, progress func(BulkProgress)) BulkProgress {
+	return produceRecords(ctx, backend, len(records), func(send func(Record) error) error {
+		for _, record := range records {
+			if err := send(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, rate, progress)
+}
+
+// ProduceFile sends the records of the file at path to topic like
+// ProduceRecords. The file is read twice, first to check and count its
+// records, then while they are sent, so the records are never held in memory
+// all at once. progress is called with the total once counted. A file which
+// cannot be read is returned as error before any record is sent.
+func ProduceFile(ctx context.Context, backend KafkaBackend, path string, format BulkFormat, topic string, rate int, progress func(BulkProgress)) (BulkProgress, error) {
+	total := 0
+	err := ScanRecordsFile(path, format, topic, func(Record) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		return BulkProgress{}, err
+	}
+	if progress != nil {
+		progress(BulkProgress{Total: total})
+	}
+
+	return produceRecords(ctx, backend, total, func(send func(Record) error) error {
+		scanned := 0
+		return ScanRecordsFile(path, format, topic, func(record Record) error {
+			if scanned++; scanned > total {
+				return fmt.Errorf("The file '%s' changed while its records were sent.", path)
+			}
+			return send(record)
+		})
+	}, rate, progress), nil
+}
+
+// produceRecords sends the total records passed to send by scan, see
+// ProduceRecords. send fails once ctx is done.
+func produceRecords(ctx context.Context, backend KafkaBackend, total int, scan func(send func(Record) error) error, rate int, progress func(BulkProgress)) BulkProgress {
+	var mu sync.Mutex
+	result := BulkProgress{Total: total}
+	report := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			result.Failed++
+			result.Err = err
+		} else {
+			result.Sent++
+		}
+		if progress != nil {
+			progress(result)
+		}
+	}
+
+	var wg sync.WaitGroup
+	queues := make([]chan Record, bulkWorkers)
+	for i := range queues {
+		queues[i] = make(chan Record)
+		wg.Add(1)
+		go func(queue <-chan Record) {
+			defer wg.Done()
+			for record := range queue {
+				_, err := backend.Produce(ctx, record)
+				report(err)
+			}
+		}(queues[i])
+	}
+
+	var tick <-chan time.Time
+	if rate > 0 && time.Second/time.Duration(rate) > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	i := 0
+	err := scan(func(record Record) error {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		select {
+		case queues[bulkQueue(i, record)] <- record:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		i++
+		return nil
+	})
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if skipped := result.Total - result.Sent - result.Failed; skipped > 0 {
+		// not sent as ctx is done, or the file changed after counting
+		result.Failed += skipped
+		result.Err = err
+		if result.Err == nil {
+			result.Err = ctx.Err()
+		}
+	}
+	result.Done = true
+	if progress != nil {
+		progress(result)
+	}
+
+	return result
+}
+
+// bulkQueue picks the worker for the i-th record, the same one for all records
+// of a partition and key to keep their order.
+func bulkQueue(i int, record Record) int {
+	if record.Key == nil && record.Partition == kafka.PartitionAny {
+		return i % bulkWorkers
+	}
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:", record.Partition)
+	h.Write(record.Key)
+	return int(h.Sum32() % bulkWorkers)
+}
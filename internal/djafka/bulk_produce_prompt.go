@@ -0,0 +1,250 @@
+package djafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	bulkFileInput = iota
+	bulkFormatInput
+	bulkRateInput
+)
+
+var bulkInputLabels = [...]string{"File", "Format", "Rate"}
+
+var bulkInputPlaceholders = []string{
+	"path of a .jsonl, .csv or text file",
+	"jsonl, csv or text (default: by extension)",
+	"records per second (default: unlimited)",
+}
+
+// bulkRun runs ProduceFile in the background. Its progress is read by the
+// commands returned from next, a file which could not be read is reported as
+// done with the error and no records.
+type bulkRun struct {
+	// updates holds the latest progress only
+	updates chan BulkProgress
+	cancel  context.CancelFunc
+}
+
+func startBulkRun(ctx context.Context, backend KafkaBackend, path string, format BulkFormat, topic string, rate int) *bulkRun {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &bulkRun{updates: make(chan BulkProgress, 1), cancel: cancel}
+	go func() {
+		if _, err := ProduceFile(ctx, backend, path, format, topic, rate, r.report); err != nil {
+			r.report(BulkProgress{Err: err, Done: true})
+		}
+	}()
+
+	return r
+}
+
+// report replaces the progress not read yet. ProduceRecords reports one
+// progress at a time, so the send never blocks.
+func (r *bulkRun) report(p BulkProgress) {
+	select {
+	case <-r.updates:
+	default:
+	}
+	r.updates <- p
+}
+
+func (r *bulkRun) next() tea.Cmd {
+	return func() tea.Msg {
+		return BulkProgressMsg{r, <-r.updates}
+	}
+}
+
+// BulkProducePrompt is the prompt to produce the records of a file to a topic,
+// showing the progress while they are sent.
+type BulkProducePrompt struct {
+	focusIndex int
+	inputs     [len(bulkInputLabels)]textinput.Model
+	bar        progress.Model
+	logger     *log.Logger
+	topic      Topic
+	status     string
+
+	// ctx and backend are kept to start a run
+	ctx      context.Context
+	backend  KafkaBackend
+	run      *bulkRun
+	progress BulkProgress
+}
+
+func InitialBulkProducePrompt(log *log.Logger, topic Topic, ctx context.Context, backend KafkaBackend) BulkProducePrompt {
+	m := BulkProducePrompt{
+		bar:     progress.New(progress.WithDefaultGradient(), progress.WithWidth(50)),
+		logger:  log,
+		topic:   topic,
+		ctx:     ctx,
+		backend: backend,
+	}
+
+	var t textinput.Model
+
+	for i := range m.inputs {
+		t = newTextInput()
+		t.CursorStyle = cursorStyle
+		t.CharLimit = 1024
+		t.Placeholder = bulkInputPlaceholders[i]
+
+		if i == bulkFileInput {
+			t.Focus()
+			t.PromptStyle = focusedStyle
+			t.TextStyle = focusedStyle
+		}
+
+		m.inputs[i] = t
+	}
+
+	return m
+}
+
+func (m BulkProducePrompt) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Stop cancels sending the records.
+func (m *BulkProducePrompt) Stop() {
+	if m.run != nil {
+		m.run.cancel()
+	}
+}
+
+// start validates the inputs and starts reading the file and sending its
+// records.
+func (m *BulkProducePrompt) start() tea.Cmd {
+	path := strings.TrimSpace(m.inputs[bulkFileInput].Value())
+	if path == "" {
+		m.status = "Enter the file to produce."
+		return nil
+	}
+
+	format, err := ParseBulkFormat(strings.TrimSpace(m.inputs[bulkFormatInput].Value()), path)
+	if err != nil {
+		m.status = err.Error()
+		return nil
+	}
+
+	rate := 0
+	if input := strings.TrimSpace(m.inputs[bulkRateInput].Value()); input != "" {
+		rate, err = strconv.Atoi(input)
+		if err != nil || rate < 0 {
+			m.status = fmt.Sprintf("Rate '%s' is not a number of records per second.", input)
+			return nil
+		}
+	}
+
+	m.logger.Printf("Producing the records of %s to %s", path, m.topic.Name)
+	m.progress = BulkProgress{}
+	m.status = fmt.Sprintf("Reading %s ...", path)
+	m.run = startBulkRun(m.ctx, m.backend, path, format, m.topic.Name, rate)
+	return m.run.next()
+}
+
+func (m BulkProducePrompt) Update(msg tea.Msg) (BulkProducePrompt, tea.Cmd) {
+	switch msg := msg.(type) {
+	case BulkProgressMsg:
+		if msg.run != m.run {
+			return m, nil
+		}
+		m.progress = msg.progress
+		if !msg.progress.Done {
+			m.status = fmt.Sprintf("Sending %d records ...", msg.progress.Total)
+			return m, m.run.next()
+		}
+		m.run = nil
+		if msg.progress.Total == 0 && msg.progress.Err != nil {
+			m.status = msg.progress.Err.Error()
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Done: %d sent, %d failed.", msg.progress.Sent, msg.progress.Failed)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", ESC:
+			return m, func() tea.Msg { return BulkProduceCancel{} }
+
+		case "enter":
+			if m.run != nil {
+				return m, nil
+			}
+			if m.focusIndex < len(m.inputs)-1 {
+				m.focusIndex++
+				return m, m.focus()
+			}
+			return m, m.start()
+
+		// Set focus to next input
+		case "tab", "shift+tab", "up", "down":
+			s := msg.String()
+
+			if s == "up" || s == "shift+tab" {
+				m.focusIndex--
+			} else {
+				m.focusIndex++
+			}
+
+			if m.focusIndex >= len(m.inputs) {
+				m.focusIndex = 0
+			} else if m.focusIndex < 0 {
+				m.focusIndex = len(m.inputs) - 1
+			}
+
+			return m, m.focus()
+		}
+	}
+
+	// Handle character input and blinking
+	cmds := make([]tea.Cmd, len(m.inputs))
+	for i := range m.inputs {
+		m.inputs[i], cmds[i] = m.inputs[i].Update(msg)
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m *BulkProducePrompt) focus() tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.inputs))
+	for i := range m.inputs {
+		if i == m.focusIndex {
+			// Set focused state
+			cmds[i] = m.inputs[i].Focus()
+			m.inputs[i].PromptStyle = focusedStyle
+			m.inputs[i].TextStyle = focusedStyle
+			continue
+		}
+		// Remove focused state
+		m.inputs[i].Blur()
+		m.inputs[i].PromptStyle = noStyle
+		m.inputs[i].TextStyle = noStyle
+	}
+
+	return tea.Batch(cmds...)
+}
+
+func (m BulkProducePrompt) View() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\n\t %s\n\n", titleStyle.Render(fmt.Sprintf("Produce file to %s", m.topic.Name)))
+	for i, input := range m.inputs {
+		fmt.Fprintf(&b, "\t %s\n\t %s\n", inputStyle.Width(30).Render(bulkInputLabels[i]), input.View())
+	}
+	if m.progress.Total > 0 {
+		fmt.Fprintf(&b, "\n\t %s\n\t %s\n", m.bar.ViewAs(m.progress.Percent()), m.progress)
+	}
+	fmt.Fprintf(&b, "\n\t %s\n\n", statusStyle.Render(m.status))
+	fmt.Fprintf(&b, "\t %s\n", helpStyle.Render("enter: next field / send • tab: next field • esc: cancel and close"))
+
+	return b.String()
+}
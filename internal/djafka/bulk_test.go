@@ -0,0 +1,188 @@
+package djafka
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestReadRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		format BulkFormat
+		input  string
+		want   []Record
+		err    string
+	}{
+		{
+			name:   "json lines",
+			format: BulkJSONLines,
+			input: `{"key": "a", "value": {"id": 1}, "headers": {"z": "1", "a": 2}, "partition": 1, "timestamp": 1685620800000}
+{"value": "text", "timestamp": "2023-06-01 12:30:00"}`,
+			want: []Record{
+				{Topic: "t", Partition: 1, Timestamp: time.UnixMilli(1685620800000), Key: []byte("a"), Value: []byte(`{"id":1}`),
+					Headers: []kafka.Header{{Key: "a", Value: []byte("2")}, {Key: "z", Value: []byte("1")}}},
+				{Topic: "t", Partition: kafka.PartitionAny, Timestamp: testTime, Value: []byte("text")},
+			},
+		},
+		{
+			name:   "json lines error",
+			format: BulkJSONLines,
+			input:  "{\"value\": 1}\n\n{\"partition\": -1}",
+			err:    "line 3: partition -1 is negative",
+		},
+		{
+			name:   "csv",
+			format: BulkCSV,
+			input:  "key,value,partition,timestamp,header.source\na,\"x,y\",0,,shop\n,z,,1685620800000,\n",
+			want: []Record{
+				{Topic: "t", Partition: 0, Key: []byte("a"), Value: []byte("x,y"), Headers: []kafka.Header{{Key: "source", Value: []byte("shop")}}},
+				{Topic: "t", Partition: kafka.PartitionAny, Timestamp: time.UnixMilli(1685620800000), Value: []byte("z")},
+			},
+		},
+		{
+			name:   "csv unknown column",
+			format: BulkCSV,
+			input:  "key,body\n",
+			err:    "unknown column 'body'",
+		},
+		{
+			name:   "text",
+			format: BulkText,
+			input:  "first\r\n\nsecond\n",
+			want: []Record{
+				{Topic: "t", Partition: kafka.PartitionAny, Value: []byte("first")},
+				{Topic: "t", Partition: kafka.PartitionAny, Value: []byte("second")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records, err := ReadRecords(strings.NewReader(test.input), test.format, "t")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(records, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, records)
+			}
+		})
+	}
+}
+
+func TestProduceRecords(t *testing.T) {
+	cluster := testCluster(t)
+	backend, err := cluster.Connect(testConfig().Connections[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	records := []Record{}
+	for i := 0; i < 20; i++ {
+		records = append(records, Record{Topic: "payments", Partition: kafka.PartitionAny, Key: []byte("same"), Value: []byte{byte('a' + i)}})
+	}
+	records = append(records, Record{Topic: "payments", Partition: 5})
+
+	start := time.Now()
+	result := ProduceRecords(context.Background(), backend, records, 200, nil)
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected 21 records at 200/s to take 100ms, took %s", elapsed)
+	}
+	if result.Total != 21 || result.Sent != 20 || result.Failed != 1 || result.Err == nil || !result.Done {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// records with the same key keep their order
+	stream := make(chan Record)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go backend.FetchMessages(ctx, "payments", StartPosition{Mode: SeekEarliest}, stream)
+	var values []byte
+	for len(values) < 20 {
+		if record := <-stream; string(record.Key) == "same" {
+			values = append(values, record.Value...)
+		}
+	}
+	if string(values) != "abcdefghijklmnopqrst" {
+		t.Errorf("expected records in order, got %s", values)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	result = ProduceRecords(ctx, backend, records, 0, nil)
+	if result.Sent+result.Failed != 21 || result.Err == nil {
+		t.Errorf("expected all records counted after cancel, got %+v", result)
+	}
+}
+
+func TestProduceFile(t *testing.T) {
+	cluster := testCluster(t)
+	backend, err := cluster.Connect(testConfig().Connections[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	dir := t.TempDir()
+	valid, invalid := filepath.Join(dir, "valid.jsonl"), filepath.Join(dir, "invalid.jsonl")
+	if err := os.WriteFile(valid, []byte("{\"key\":\"a\",\"value\":1}\n\n{\"key\":\"b\",\"value\":2}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(invalid, []byte("{\"key\":\"c\"}\n{\"partition\":-1}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var reported []BulkProgress
+	result, err := ProduceFile(context.Background(), backend, valid, BulkJSONLines, "payments", 0, func(p BulkProgress) {
+		reported = append(reported, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 2 || result.Sent != 2 || !result.Done {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(reported) == 0 || reported[0] != (BulkProgress{Total: 2}) {
+		t.Errorf("expected the total to be reported first, got %+v", reported)
+	}
+
+	// nothing is sent of a file with an invalid record
+	if _, err := ProduceFile(context.Background(), backend, invalid, BulkJSONLines, "payments", 0, nil); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error for line 2, got %v", err)
+	}
+	stream := make(chan Record)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go backend.FetchMessages(ctx, "payments", StartPosition{Mode: SeekEarliest}, stream)
+	keys := ""
+	for len(keys) < 2 {
+		keys += string((<-stream).Key)
+	}
+	select {
+	case record := <-stream:
+		t.Errorf("expected no record of the invalid file, got %s", record.Key)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if keys != "ab" {
+		t.Errorf("expected the records a and b, got %s", keys)
+	}
+}
+
+func TestRunProduceNegativeRate(t *testing.T) {
+	if code := RunProduce("config.json", []string{"--topic", "orders", "--file", "orders.jsonl", "--rate", "-1"}); code != 2 {
+		t.Errorf("Expected exit code 2 for a negative rate, got %d", code)
+	}
+}
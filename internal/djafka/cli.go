@@ -0,0 +1,142 @@
+package djafka
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+)
+
+// RunProduce is the produce command, which sends the records of a file to a
+// topic without starting the TUI. It returns the exit code.
+func RunProduce(configPath string, args []string) int {
+	flags := flag.NewFlagSet("produce", flag.ContinueOnError)
+	connection := flags.String("connection", "", "name of the connection (default: the only connection of the config)")
+	topic := flags.String("topic", "", "topic to produce to")
+	file := flags.String("file", "", "file of records to produce")
+	format := flags.String("format", "", "jsonl, csv or text (default: by the file extension)")
+	rate := flags.Int("rate", 0, "maximum records per second (default: unlimited)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *topic == "" || *file == "" {
+		fmt.Fprintln(os.Stderr, "produce needs --topic and --file")
+		flags.Usage()
+		return 2
+	}
+	if *rate < 0 {
+		fmt.Fprintln(os.Stderr, "--rate must not be negative")
+		flags.Usage()
+		return 2
+	}
+
+	config, err := ReadConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	conn, err := cliConnection(config, *connection)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	bulkFormat, err := ParseBulkFormat(*format, *file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	backend, err := ConnectService(conn, log.New(io.Discard, "", 0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer backend.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := produceWithProgress(ctx, os.Stderr, backend, *file, bulkFormat, *topic, *rate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%d records sent to %s, %d failed\n", result.Sent, *topic, result.Failed)
+	if result.Failed > 0 {
+		fmt.Fprintln(os.Stderr, "Last error:", result.Err)
+		return 1
+	}
+
+	return 0
+}
+
+// cliConnection returns the connection named name, or the only one of config
+// without a name.
+func cliConnection(config *Config, name string) (Connection, error) {
+	if name != "" {
+		return config.FindConnection(name)
+	}
+	if len(config.Connections) == 1 {
+		return config.Connections[0], nil
+	}
+
+	names := make([]string, 0, len(config.Connections))
+	for _, conn := range config.Connections {
+		names = append(names, conn.Name)
+	}
+	return Connection{}, fmt.Errorf("Choose a connection with --connection: %s", strings.Join(names, ", "))
+}
+
+// produceWithProgress runs ProduceFile and redraws a progress bar on out every
+// 100ms once the records of the file are counted.
+func produceWithProgress(ctx context.Context, out io.Writer, backend KafkaBackend, path string, format BulkFormat, topic string, rate int) (BulkProgress, error) {
+	bar := progress.New(progress.WithDefaultGradient(), progress.WithWidth(40))
+
+	var mu sync.Mutex
+	var current *BulkProgress
+	draw := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if current != nil {
+			fmt.Fprintf(out, "\r%s %s", bar.ViewAs(current.Percent()), current)
+		}
+	}
+
+	type outcome struct {
+		result BulkProgress
+		err    error
+	}
+	done := make(chan outcome)
+	go func() {
+		result, err := ProduceFile(ctx, backend, path, format, topic, rate, func(p BulkProgress) {
+			mu.Lock()
+			current = &p
+			mu.Unlock()
+		})
+		done <- outcome{result, err}
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			draw()
+		case o := <-done:
+			if o.err != nil {
+				return o.result, o.err
+			}
+			draw()
+			fmt.Fprintln(out)
+			return o.result, nil
+		}
+	}
+}
@@ -0,0 +1,538 @@
+package djafka
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+type Connection struct {
+	Name             string                   `json:"name"`
+	BootstrapServers []string                 `json:"bootstrapServers"`
+	Properties       map[string]string        `json:"properties,omitempty"`
+	Security         *Security                `json:"security,omitempty"`
+	TimeoutMs        int                      `json:"timeoutMs,omitempty"`
+	TailSize         int                      `json:"tailSize,omitempty"`
+	BrowseSize       int                      `json:"browseSize,omitempty"`
+	SchemaRegistry   *SchemaRegistryConfig    `json:"schemaRegistry,omitempty"`
+	Topics           map[string]TopicSettings `json:"topics,omitempty"`
+	Source           ConfigSource             `json:"-"`
+}
+
+// TopicSettings are remembered per topic of a connection. HeaderColumns
+// names the headers shown as columns of the messages view.
+type TopicSettings struct {
+	Key           *Serde   `json:"key,omitempty"`
+	Value         *Serde   `json:"value,omitempty"`
+	HeaderColumns []string `json:"headerColumns,omitempty"`
+}
+
+// Serde names the format keys or values of a topic are shown in, see
+// DeserializerFormats. Schema is the schema file of formats needing one,
+// relative to the config file. Protobuf messages of the type named by Message
+// are decoded with the descriptor set or the .proto files at Schema.
+type Serde struct {
+	Format  string `json:"format"`
+	Schema  string `json:"schema,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (s *Serde) validate() error {
+	if s == nil {
+		return nil
+	}
+	for _, format := range DeserializerFormats {
+		if s.Format == format {
+			if format == FormatAvro && s.Schema == "" {
+				return fmt.Errorf("the avro format needs a schema file")
+			}
+			if format == FormatProtobuf && s.Message != "" && s.Schema == "" {
+				return fmt.Errorf("the protobuf message type '%s' needs a descriptor set or .proto files as schema", s.Message)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format '%s', expected one of %s", s.Format, strings.Join(DeserializerFormats, ", "))
+}
+
+// DefaultRequestTimeout is used for requests to the cluster if the connection
+// does not configure a timeout.
+const DefaultRequestTimeout = 5 * time.Second
+
+// DefaultTailSize is the number of records kept while tailing a topic if the
+// connection does not configure it.
+const DefaultTailSize = 1000
+
+// TailBufferSize returns how many of the last records are kept while tailing
+// a topic.
+func (c Connection) TailBufferSize() int {
+	if c.TailSize <= 0 {
+		return DefaultTailSize
+	}
+
+	return c.TailSize
+}
+
+// DefaultBrowseSize is the number of records kept while browsing a topic if
+// the connection does not configure it.
+const DefaultBrowseSize = 10000
+
+// BrowseBufferSize returns how many of the last records read are kept while
+// browsing a topic.
+func (c Connection) BrowseBufferSize() int {
+	if c.BrowseSize <= 0 {
+		return DefaultBrowseSize
+	}
+
+	return c.BrowseSize
+}
+
+// RequestTimeout returns how long requests to the cluster may take.
+func (c Connection) RequestTimeout() time.Duration {
+	if c.TimeoutMs <= 0 {
+		return DefaultRequestTimeout
+	}
+
+	return time.Duration(c.TimeoutMs) * time.Millisecond
+}
+
+const (
+	ConfigFileName = "config"
+	ConfigEnv      = "DJAFKA_CONFIG"
+
+	SourceFlag    = "--config"
+	SourceEnv     = "$" + ConfigEnv
+	SourceUser    = "user"
+	SourceProject = "project"
+)
+
+// ConfigSource is the config file a connection was read from.
+type ConfigSource struct {
+	Kind string
+	Path string
+}
+
+// ErrNoConfig is returned by ReadConfig when no config file exists yet.
+var ErrNoConfig = errors.New("no config file exists")
+
+type Config struct {
+	Version     int          `json:"version"`
+	Connections []Connection `json:"connections"`
+}
+
+func (c *Config) FindConnection(name string) (Connection, error) {
+	for _, conn := range c.Connections {
+		if conn.Name == name {
+			return conn, nil
+		}
+	}
+
+	return Connection{}, fmt.Errorf("Failed to find connection for name '%s'.", name)
+}
+
+// ReadConfig reads the connections from the config file given by path or the
+// DJAFKA_CONFIG environment variable. Without either, the user level config in
+// the XDG config directory is read first and a config.json in the working
+// directory may add connections or override them by name.
+func ReadConfig(path string) (*Config, error) {
+	sources, err := configSources(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Config{Version: CurrentConfigVersion}
+	for _, source := range sources {
+		layer, err := readConfigFile(source)
+		if err != nil {
+			return nil, err
+		}
+		config.merge(layer)
+	}
+
+	return &config, nil
+}
+
+func readConfigFile(source ConfigSource) (*Config, error) {
+	content, err := os.ReadFile(source.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("Failed to read config file '%s': %w", source.Path, ErrNoConfig)
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read config file: %w", err)
+	}
+
+	config, err := decodeConfig(source.Path, content)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range config.Connections {
+		config.Connections[i].Source = source
+	}
+
+	return config, nil
+}
+
+// merge adds the connections of other to c, replacing connections with the
+// same name.
+func (c *Config) merge(other *Config) {
+	for _, conn := range other.Connections {
+		replaced := false
+		for i := range c.Connections {
+			if c.Connections[i].Name == conn.Name {
+				c.Connections[i] = conn
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			c.Connections = append(c.Connections, conn)
+		}
+	}
+}
+
+// configSources returns the config files to read, lowest precedence first.
+func configSources(path string) ([]ConfigSource, error) {
+	if path != "" {
+		return []ConfigSource{{SourceFlag, path}}, nil
+	}
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return []ConfigSource{{SourceEnv, path}}, nil
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve working directory: %w", err)
+	}
+
+	sources := []ConfigSource{}
+	candidates := []ConfigSource{{SourceUser, userConfigDir()}, {SourceProject, workingDir}}
+	for _, candidate := range candidates {
+		if path := findConfigFile(candidate.Path); path != "" {
+			sources = append(sources, ConfigSource{candidate.Kind, path})
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("Failed to find a config file named %s{%s} in '%s' or '%s': %w",
+			ConfigFileName, strings.Join(ConfigFormats, ","), candidates[0].Path, candidates[1].Path, ErrNoConfig)
+	}
+
+	return sources, nil
+}
+
+// NewConfigSource returns the file a new config is written to: the one given
+// by path or DJAFKA_CONFIG if set, the user level config otherwise.
+func NewConfigSource(path string) ConfigSource {
+	if path != "" {
+		return ConfigSource{SourceFlag, path}
+	}
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return ConfigSource{SourceEnv, path}
+	}
+
+	return ConfigSource{SourceUser, filepath.Join(userConfigDir(), ConfigFileName+ConfigFormats[0])}
+}
+
+// WriteConfig replaces the config file at path. The config is written to a
+// temporary file first which is then renamed, so readers never see a partially
+// written file. An existing YAML file keeps its comments and the order of its
+// keys.
+func WriteConfig(path string, config *Config) error {
+	config.Version = CurrentConfigVersion
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Failed to read config file: %w", err)
+	}
+	content, err := encodeConfig(path, config, existing)
+	if err != nil {
+		return fmt.Errorf("Failed to encode config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("Failed to create config directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("Failed to write config file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return fmt.Errorf("Failed to write config file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("Failed to write config file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("Failed to write config file: %w", err)
+	}
+
+	// config files may contain credentials
+	if err := os.Chmod(file.Name(), 0o600); err != nil {
+		return fmt.Errorf("Failed to write config file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("Failed to replace config file '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// RewriteError is returned instead of saving to a config file which would lose
+// its comments or the order of its keys, as TOML files are written from
+// scratch.
+type RewriteError struct {
+	Path string
+}
+
+func (e *RewriteError) Error() string {
+	return fmt.Sprintf("Saving rewrites '%s' without its comments and in another order.", e.Path)
+}
+
+// checkRewrite returns a RewriteError if the TOML file at path differs from
+// how djafka writes its config, e.g. because it was edited by hand.
+func checkRewrite(path string) error {
+	if strings.ToLower(filepath.Ext(path)) != ".toml" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || len(bytes.TrimSpace(content)) == 0 {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to read config file: %w", err)
+	}
+
+	config, err := decodeConfig(path, content)
+	if err != nil {
+		return err
+	}
+	written, err := encodeConfig(path, config, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to encode config: %w", err)
+	}
+	if !bytes.Equal(written, content) {
+		return &RewriteError{path}
+	}
+
+	return nil
+}
+
+// SaveConnection stores conn in the config file at path, creating the file if
+// necessary. previous is the name of the connection replaced by conn and empty
+// when conn is added. Unless rewrite is set, a TOML file which would lose its
+// comments is left alone and a RewriteError returned.
+func SaveConnection(path string, previous string, conn Connection, rewrite bool) error {
+	if !rewrite {
+		if err := checkRewrite(path); err != nil {
+			return err
+		}
+	}
+	config, err := readConfigFile(ConfigSource{Path: path})
+	if errors.Is(err, ErrNoConfig) {
+		config = &Config{}
+	} else if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range config.Connections {
+		if previous != "" && config.Connections[i].Name == previous {
+			config.Connections[i] = conn
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		config.Connections = append(config.Connections, conn)
+	}
+
+	if problems := config.validate(); len(problems) > 0 {
+		return &ConfigError{path, problems}
+	}
+
+	return WriteConfig(path, config)
+}
+
+// SaveTopicSettings stores the settings of a topic of the connection with the
+// given name in the config file at path. rewrite is used like by
+// SaveConnection.
+func SaveTopicSettings(path string, name string, topic string, settings TopicSettings, rewrite bool) error {
+	if !rewrite {
+		if err := checkRewrite(path); err != nil {
+			return err
+		}
+	}
+	config, err := readConfigFile(ConfigSource{Path: path})
+	if err != nil {
+		return err
+	}
+
+	for i := range config.Connections {
+		if config.Connections[i].Name != name {
+			continue
+		}
+		if config.Connections[i].Topics == nil {
+			config.Connections[i].Topics = map[string]TopicSettings{}
+		}
+		config.Connections[i].Topics[topic] = settings
+		return WriteConfig(path, config)
+	}
+
+	return fmt.Errorf("Failed to find connection '%s' in '%s'.", name, path)
+}
+
+// DeleteConnection removes the connection with the given name from the config
+// file at path. It is confirmed before, with the warning of checkRewrite.
+func DeleteConnection(path string, name string) error {
+	config, err := readConfigFile(ConfigSource{Path: path})
+	if err != nil {
+		return err
+	}
+
+	connections := []Connection{}
+	for _, conn := range config.Connections {
+		if conn.Name != name {
+			connections = append(connections, conn)
+		}
+	}
+	if len(connections) == len(config.Connections) {
+		return fmt.Errorf("Failed to find connection '%s' in '%s'.", name, path)
+	}
+	config.Connections = connections
+
+	return WriteConfig(path, config)
+}
+
+// findConfigFile returns the first config file in dir with a supported format.
+func findConfigFile(dir string) string {
+	for _, ext := range ConfigFormats {
+		path := filepath.Join(dir, ConfigFileName+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// userConfigDir returns the directory of the user level config file,
+// following the XDG base directory specification on every platform.
+func userConfigDir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "djafka")
+}
+
+// Servers returns every bootstrap server of the connection. Entries may
+// contain comma separated lists like librdkafka's bootstrap.servers.
+func (c Connection) Servers() []string {
+	servers := []string{}
+	for _, server := range c.BootstrapServers {
+		for _, s := range strings.Split(server, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				servers = append(servers, s)
+			}
+		}
+	}
+
+	return servers
+}
+
+// ClientConfig builds the librdkafka configuration for a client of this
+// connection. The security settings are applied first, followed by the
+// connection properties and the client specific overrides (e.g. the consumer
+// group), each taking precedence over the previous ones.
+func (c Connection) ClientConfig(overrides kafka.ConfigMap) (*kafka.ConfigMap, error) {
+	servers := c.Servers()
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("Connection '%s' has no bootstrap servers configured.", c.Name)
+	}
+
+	config := kafka.ConfigMap{}
+	if c.Security != nil {
+		props, err := c.Security.properties()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid security settings for connection '%s': %w", c.Name, err)
+		}
+		for key, value := range props {
+			config[key] = value
+		}
+	}
+
+	for key, value := range c.Properties {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("Connection '%s' contains a property with an empty name.", c.Name)
+		}
+		if key == "bootstrap.servers" {
+			return nil, fmt.Errorf("Connection '%s' sets 'bootstrap.servers' as property, use 'bootstrapServers' instead.", c.Name)
+		}
+		config[key] = value
+	}
+
+	for key, value := range overrides {
+		config[key] = value
+	}
+	config["bootstrap.servers"] = strings.Join(servers, ",")
+
+	return &config, nil
+}
+
+// ResolveSecrets returns a copy of the connection with every secret reference
+// in its security settings and secret properties replaced by the referenced
+// value.
+// The copy must never be logged or written back to the config file.
+func (c Connection) ResolveSecrets() (Connection, error) {
+	resolved := c
+	if c.Security != nil {
+		security, err := c.Security.resolveSecrets(c.Name)
+		if err != nil {
+			return Connection{}, err
+		}
+		resolved.Security = security
+	}
+
+	if c.SchemaRegistry != nil {
+		registry := *c.SchemaRegistry
+		password, err := resolveSecret(registry.Password)
+		if err != nil {
+			return Connection{}, &SecretError{c.Name, registry.Password, err}
+		}
+		registry.Password = password
+		resolved.SchemaRegistry = &registry
+	}
+
+	if c.Properties != nil {
+		resolved.Properties = map[string]string{}
+		for key, ref := range c.Properties {
+			if !secretProperties[strings.TrimSpace(key)] {
+				resolved.Properties[key] = ref
+				continue
+			}
+			value, err := resolveSecret(ref)
+			if err != nil {
+				return Connection{}, &SecretError{c.Name, ref, err}
+			}
+			resolved.Properties[key] = value
+		}
+	}
+
+	return resolved, nil
+}
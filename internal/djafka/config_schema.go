@@ -0,0 +1,449 @@
+package djafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion is the version of the config layout written by djafka.
+// Older layouts are migrated when read.
+const CurrentConfigVersion = 2
+
+// configMigrations upgrade a decoded config from the version of their index
+// plus one to the next version. Files without a version are treated as
+// version 1.
+var configMigrations = []func(raw map[string]any){
+	migrateBootstrapServerList,
+}
+
+// ConfigFormats are the supported config file extensions in lookup order.
+var ConfigFormats = []string{".json", ".yaml", ".yml", ".toml"}
+
+// ConfigError lists every problem found in a config file.
+type ConfigError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("Invalid config file '%s':\n  - %s", e.Path, strings.Join(e.Problems, "\n  - "))
+}
+
+// decodeConfig parses the content of a config file in the format given by
+// the extension of path, migrates it to the current version and validates it.
+func decodeConfig(path string, content []byte) (*Config, error) {
+	// an empty file is a config without connections yet
+	if len(bytes.TrimSpace(content)) == 0 {
+		return &Config{Version: CurrentConfigVersion}, nil
+	}
+
+	raw := map[string]any{}
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		err = json.Unmarshal(content, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode config file '%s': %w", path, err)
+	}
+
+	// yaml and toml decode to more specific types than json, e.g. arrays of
+	// tables to []map[string]any, so all formats are converted to the json
+	// representation first
+	if err := normalizeRaw(&raw); err != nil {
+		return nil, fmt.Errorf("Failed to decode config file '%s': %w", path, err)
+	}
+
+	problems := migrateConfig(raw)
+	normalizeProperties(raw)
+	problems = append(problems, unknownKeys(raw, reflect.TypeOf(Config{}), "")...)
+
+	// the migrated document is decoded once more to get typed values
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode config file '%s': %w", path, err)
+	}
+	config := Config{}
+	if err := json.Unmarshal(normalized, &config); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return nil, &ConfigError{path, problems}
+	}
+
+	return &config, nil
+}
+
+// encodeConfig serializes the config in the format given by the extension of
+// path. The existing content of a YAML file is patched, so its comments and
+// the order of its keys are kept.
+func encodeConfig(path string, config *Config, existing []byte) ([]byte, error) {
+	content, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yaml" && ext != ".yml" && ext != ".toml" {
+		return append(content, '\n'), nil
+	}
+
+	raw := map[string]any{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+
+	integers(raw)
+
+	var buf bytes.Buffer
+	if ext == ".toml" {
+		err = toml.NewEncoder(&buf).Encode(raw)
+		return buf.Bytes(), err
+	}
+
+	var value yaml.Node
+	if err := value.Encode(raw); err != nil {
+		return nil, err
+	}
+	document := yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&value}}
+	var previous yaml.Node
+	if err := yaml.Unmarshal(existing, &previous); err == nil && len(previous.Content) == 1 {
+		patchNode(previous.Content[0], &value)
+		document = previous
+	}
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&document)
+
+	return buf.Bytes(), err
+}
+
+// patchNode changes node to value, keeping the comments, the styles and the
+// order of the keys of node where they still apply.
+func patchNode(node *yaml.Node, value *yaml.Node) {
+	if node.Kind != value.Kind || node.Kind == yaml.AliasNode {
+		head, line, foot := node.HeadComment, node.LineComment, node.FootComment
+		*node = *value
+		node.HeadComment, node.LineComment, node.FootComment = head, line, foot
+		return
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		// the value of e.g. a property may change its type, not its text
+		if node.Value != value.Value {
+			node.Value, node.Tag, node.Style = value.Value, value.Tag, value.Style
+		}
+	case yaml.MappingNode:
+		content := []*yaml.Node{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if item := mappingValue(value, node.Content[i].Value); item != nil {
+				patchNode(node.Content[i+1], item)
+				content = append(content, node.Content[i], node.Content[i+1])
+			}
+		}
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			if mappingValue(node, value.Content[i].Value) == nil {
+				content = append(content, value.Content[i], value.Content[i+1])
+			}
+		}
+		node.Content = content
+	case yaml.SequenceNode:
+		// items are matched by name, e.g. connections, otherwise by index
+		names := map[string]bool{}
+		for _, item := range value.Content {
+			names[itemName(item)] = true
+		}
+		used := map[*yaml.Node]bool{}
+		content := make([]*yaml.Node, len(value.Content))
+		for i, item := range value.Content {
+			content[i] = item
+			var match *yaml.Node
+			for _, previous := range node.Content {
+				if !used[previous] && itemName(item) != "" && itemName(previous) == itemName(item) {
+					match = previous
+					break
+				}
+			}
+			if match == nil && i < len(node.Content) && !used[node.Content[i]] {
+				// a renamed item takes the place of the old name
+				if name := itemName(node.Content[i]); name == "" || !names[name] {
+					match = node.Content[i]
+				}
+			}
+			if match != nil {
+				used[match] = true
+				patchNode(match, item)
+				content[i] = match
+			}
+		}
+		node.Content = content
+	}
+}
+
+// mappingValue returns the value of key in the mapping node, nil if missing.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// itemName returns the name of a mapping node in a sequence, empty if it has
+// none.
+func itemName(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	if name := mappingValue(node, "name"); name != nil && name.Kind == yaml.ScalarNode {
+		return name.Value
+	}
+	return ""
+}
+
+// integers converts whole numbers, which json decodes as float64, back to
+// integers, so they are not written as floats to yaml and toml.
+func integers(value any) any {
+	switch v := value.(type) {
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+	case map[string]any:
+		for key, item := range v {
+			v[key] = integers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = integers(item)
+		}
+	}
+
+	return value
+}
+
+func migrateConfig(raw map[string]any) []string {
+	version := 1
+	if value, ok := raw["version"]; ok {
+		number, ok := configVersion(value)
+		if !ok {
+			// reported once, not again when decoding the typed config
+			delete(raw, "version")
+			return []string{fmt.Sprintf("version: expected a positive number, got '%v'", value)}
+		}
+		version = number
+	}
+
+	if version > CurrentConfigVersion {
+		return []string{fmt.Sprintf("version: %d is newer than the supported version %d, please update djafka", version, CurrentConfigVersion)}
+	}
+
+	for ; version < CurrentConfigVersion; version++ {
+		configMigrations[version-1](raw)
+	}
+	raw["version"] = CurrentConfigVersion
+
+	return nil
+}
+
+// configVersion returns the version as positive integer.
+func configVersion(value any) (int, bool) {
+	number, ok := value.(float64)
+	return int(number), ok && number > 0 && number == float64(int(number))
+}
+
+// migrateBootstrapServerList moves the single "bootstrapServer" of version 1
+// connections into the "bootstrapServers" list.
+func migrateBootstrapServerList(raw map[string]any) {
+	connections, _ := raw["connections"].([]any)
+	for _, item := range connections {
+		conn, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		server, ok := conn["bootstrapServer"].(string)
+		if !ok {
+			continue
+		}
+		delete(conn, "bootstrapServer")
+
+		servers := []any{}
+		for _, s := range strings.Split(server, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				servers = append(servers, s)
+			}
+		}
+		existing, _ := conn["bootstrapServers"].([]any)
+		conn["bootstrapServers"] = append(servers, existing...)
+	}
+}
+
+func normalizeRaw(raw *map[string]any) error {
+	content, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, raw)
+}
+
+// normalizeProperties converts numbers and booleans in connection properties
+// to strings, as yaml and toml decode unquoted values with their own type.
+func normalizeProperties(raw map[string]any) {
+	connections, _ := raw["connections"].([]any)
+	for _, item := range connections {
+		conn, _ := item.(map[string]any)
+		properties, _ := conn["properties"].(map[string]any)
+		for key, value := range properties {
+			switch value.(type) {
+			case bool, float64:
+				properties[key] = fmt.Sprint(value)
+			}
+		}
+	}
+}
+
+// unknownKeys returns the paths of all keys in raw which do not match a json
+// field of t.
+func unknownKeys(raw any, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	problems := []string{}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return nil
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldType, known := fields[key]
+			if !known {
+				problems = append(problems, fmt.Sprintf("%s: unknown key", joinPath(path, key)))
+				continue
+			}
+			problems = append(problems, unknownKeys(object[key], fieldType, joinPath(path, key))...)
+		}
+	case reflect.Slice:
+		items, ok := raw.([]any)
+		if !ok {
+			return nil
+		}
+		for i, item := range items {
+			problems = append(problems, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case reflect.Map:
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return nil
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			problems = append(problems, unknownKeys(object[key], t.Elem(), joinPath(path, key))...)
+		}
+	}
+
+	return problems
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// validate returns every semantic problem of the config.
+func (c *Config) validate() []string {
+	problems := []string{}
+	seen := map[string]bool{}
+	for i, conn := range c.Connections {
+		path := fmt.Sprintf("connections[%d]", i)
+		if strings.TrimSpace(conn.Name) == "" {
+			problems = append(problems, fmt.Sprintf("%s.name: must not be empty", path))
+		} else if seen[conn.Name] {
+			problems = append(problems, fmt.Sprintf("%s.name: duplicate connection '%s'", path, conn.Name))
+		}
+		seen[conn.Name] = true
+
+		if conn.TimeoutMs < 0 {
+			problems = append(problems, fmt.Sprintf("%s.timeoutMs: must not be negative", path))
+		}
+		if conn.TailSize < 0 {
+			problems = append(problems, fmt.Sprintf("%s.tailSize: must not be negative", path))
+		}
+		if conn.BrowseSize < 0 {
+			problems = append(problems, fmt.Sprintf("%s.browseSize: must not be negative", path))
+		}
+		if len(conn.Servers()) == 0 {
+			problems = append(problems, fmt.Sprintf("%s.bootstrapServers: must not be empty", path))
+		}
+		for key := range conn.Properties {
+			if strings.TrimSpace(key) == "" {
+				problems = append(problems, fmt.Sprintf("%s.properties: property name must not be empty", path))
+			} else if key == "bootstrap.servers" {
+				problems = append(problems, fmt.Sprintf("%s.properties: use bootstrapServers instead of '%s'", path, key))
+			}
+		}
+		if conn.Security != nil {
+			if _, err := conn.Security.properties(); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.security: %s", path, err))
+			}
+		}
+		if conn.SchemaRegistry != nil {
+			if err := conn.SchemaRegistry.validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.schemaRegistry: %s", path, err))
+			}
+		}
+		topics := make([]string, 0, len(conn.Topics))
+		for topic := range conn.Topics {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		for _, topic := range topics {
+			topicPath := joinPath(path+".topics", topic)
+			if err := conn.Topics[topic].Key.validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.key: %s", topicPath, err))
+			}
+			if err := conn.Topics[topic].Value.validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.value: %s", topicPath, err))
+			}
+		}
+	}
+
+	return problems
+}
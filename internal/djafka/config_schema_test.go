@@ -0,0 +1,283 @@
+package djafka
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	want := &Config{
+		Version: CurrentConfigVersion,
+		Connections: []Connection{{
+			Name:             "local",
+			BootstrapServers: []string{"a:9092", "b:9092"},
+			Properties:       map[string]string{"socket.timeout.ms": "1000", "enable.idempotence": "true"},
+			Security:         &Security{Mechanism: MechanismPlain, Username: "user", Password: "env:KAFKA_PASS"},
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    *Config
+	}{
+		{
+			name: "json",
+			path: "config.json",
+			content: `{"version": 2, "connections": [{
+				"name": "local",
+				"bootstrapServers": ["a:9092", "b:9092"],
+				"properties": {"socket.timeout.ms": "1000", "enable.idempotence": "true"},
+				"security": {"mechanism": "PLAIN", "username": "user", "password": "env:KAFKA_PASS"}
+			}]}`,
+			want: want,
+		},
+		{
+			name: "yaml",
+			path: "config.yaml",
+			content: `version: 2
+connections:
+  - name: local
+    bootstrapServers: [a:9092, b:9092]
+    properties:
+      socket.timeout.ms: 1000
+      enable.idempotence: true
+    security:
+      mechanism: PLAIN
+      username: user
+      password: env:KAFKA_PASS
+`,
+			want: want,
+		},
+		{
+			name: "toml",
+			path: "config.toml",
+			content: `version = 2
+
+[[connections]]
+name = "local"
+bootstrapServers = ["a:9092", "b:9092"]
+
+[connections.properties]
+"socket.timeout.ms" = 1000
+"enable.idempotence" = true
+
+[connections.security]
+mechanism = "PLAIN"
+username = "user"
+password = "env:KAFKA_PASS"
+`,
+			want: want,
+		},
+		{
+			name:    "empty file",
+			path:    "config.yaml",
+			content: " \n",
+			want:    &Config{Version: CurrentConfigVersion},
+		},
+		{
+			name:    "version 1 without version",
+			path:    "config.json",
+			content: `{"connections": [{"name": "local", "bootstrapServer": "a:9092, b:9092"}]}`,
+			want: &Config{
+				Version:     CurrentConfigVersion,
+				Connections: []Connection{{Name: "local", BootstrapServers: []string{"a:9092", "b:9092"}}},
+			},
+		},
+		{
+			name:    "version 1 with servers of both layouts",
+			path:    "config.yml",
+			content: "version: 1\nconnections:\n  - name: local\n    bootstrapServer: a:9092\n    bootstrapServers: [b:9092]\n",
+			want: &Config{
+				Version:     CurrentConfigVersion,
+				Connections: []Connection{{Name: "local", BootstrapServers: []string{"a:9092", "b:9092"}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		config, err := decodeConfig(test.path, []byte(test.content))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, test.want) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.want, config)
+		}
+	}
+}
+
+func TestDecodeConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		err      string
+		problems []string
+	}{
+		{
+			name:    "invalid json",
+			path:    "config.json",
+			content: `{"connections": [`,
+			err:     "Failed to decode config file 'config.json'",
+		},
+		{
+			name:    "invalid yaml",
+			path:    "config.yaml",
+			content: "connections: [\n",
+			err:     "Failed to decode config file 'config.yaml'",
+		},
+		{
+			name:    "invalid toml",
+			path:    "config.toml",
+			content: "[[connections]\n",
+			err:     "Failed to decode config file 'config.toml'",
+		},
+		{
+			name:     "invalid version",
+			path:     "config.json",
+			content:  `{"version": "two", "connections": []}`,
+			problems: []string{"version: expected a positive number, got 'two'"},
+		},
+		{
+			name:     "newer version",
+			path:     "config.json",
+			content:  `{"version": 3, "connections": []}`,
+			problems: []string{"version: 3 is newer than the supported version 2, please update djafka"},
+		},
+		{
+			name: "unknown keys",
+			path: "config.json",
+			content: `{"version": 2, "conections": [], "connections": [{
+				"name": "local", "bootstrapServers": ["a:9092"], "security": {"user": "me"}
+			}]}`,
+			problems: []string{"conections: unknown key", "connections[0].security.user: unknown key"},
+		},
+		{
+			name: "invalid connections",
+			path: "config.json",
+			content: `{"version": 2, "connections": [
+				{"name": "local", "bootstrapServers": ["a:9092"], "timeoutMs": -1},
+				{"name": "local", "bootstrapServers": [" "], "tailSize": -1, "browseSize": -1},
+				{"name": "", "bootstrapServers": ["a:9092"], "properties": {"bootstrap.servers": "b:9092"}},
+				{"name": "secure", "bootstrapServers": ["a:9092"], "security": {"protocol": "SASL_SSL"}}
+			]}`,
+			problems: []string{
+				"connections[0].timeoutMs: must not be negative",
+				"connections[1].name: duplicate connection 'local'",
+				"connections[1].tailSize: must not be negative",
+				"connections[1].browseSize: must not be negative",
+				"connections[1].bootstrapServers: must not be empty",
+				"connections[2].name: must not be empty",
+				"connections[2].properties: use bootstrapServers instead of 'bootstrap.servers'",
+				"connections[3].security: protocol SASL_SSL requires a mechanism",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := decodeConfig(test.path, []byte(test.content))
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if test.err != "" && !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+		if test.problems == nil {
+			continue
+		}
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) {
+			t.Errorf("%s: expected a ConfigError, got %v", test.name, err)
+			continue
+		}
+		if configErr.Path != test.path || !reflect.DeepEqual(configErr.Problems, test.problems) {
+			t.Errorf("%s: expected the problems\n%s\ngot\n%s", test.name, strings.Join(test.problems, "\n"), strings.Join(configErr.Problems, "\n"))
+		}
+	}
+}
+
+func TestSaveConnectionKeepsYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, `# clusters of the team
+version: 2
+connections:
+  # the local cluster
+  - name: local
+    bootstrapServers:
+      - localhost:9092 # started by docker compose
+    properties:
+      session.timeout.ms: 10000
+  - name: staging
+    bootstrapServers:
+      - staging:9092
+`)
+
+	conn := Connection{Name: "qa", BootstrapServers: []string{"qa:9092"}}
+	if err := SaveConnection(path, "staging", conn, false); err != nil {
+		t.Fatal(err)
+	}
+	conn = Connection{Name: "local", BootstrapServers: []string{"localhost:9093"}, Properties: map[string]string{"session.timeout.ms": "10000"}}
+	if err := SaveConnection(path, "local", conn, false); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# clusters of the team
+version: 2
+connections:
+  # the local cluster
+  - name: local
+    bootstrapServers:
+      - localhost:9093 # started by docker compose
+    properties:
+      session.timeout.ms: 10000
+  - name: qa
+    bootstrapServers:
+      - qa:9092
+`
+	if string(content) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, content)
+	}
+}
+
+func TestSaveConnectionRewritesTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfig(t, path, `# clusters of the team
+version = 2
+
+[[connections]]
+name = "local"
+bootstrapServers = ["localhost:9092"]
+`)
+
+	conn := Connection{Name: "qa", BootstrapServers: []string{"qa:9092"}}
+	var rewriteErr *RewriteError
+	if err := SaveConnection(path, "", conn, false); !errors.As(err, &rewriteErr) || rewriteErr.Path != path {
+		t.Fatalf("Expected a RewriteError, got %v", err)
+	}
+	if err := SaveConnection(path, "", conn, true); err != nil {
+		t.Fatal(err)
+	}
+	config, err := readConfigFile(ConfigSource{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Connections) != 2 {
+		t.Errorf("Expected the connection to be added, got %v", config.Connections)
+	}
+
+	// once written by djafka, the file is saved without asking again
+	if err := SaveTopicSettings(path, "qa", "orders", TopicSettings{HeaderColumns: []string{"trace"}}, false); err != nil {
+		t.Error(err)
+	}
+}
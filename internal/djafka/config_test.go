@@ -0,0 +1,285 @@
+package djafka
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestClientConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		conn      Connection
+		overrides kafka.ConfigMap
+		want      kafka.ConfigMap
+		err       string
+	}{
+		{
+			name: "servers",
+			conn: Connection{Name: "local", BootstrapServers: []string{"a:9092"}},
+			want: kafka.ConfigMap{"bootstrap.servers": "a:9092"},
+		},
+		{
+			name: "comma separated servers",
+			conn: Connection{Name: "local", BootstrapServers: []string{" a:9092, b:9092 ", "", "c:9092,"}},
+			want: kafka.ConfigMap{"bootstrap.servers": "a:9092,b:9092,c:9092"},
+		},
+		{
+			name: "properties",
+			conn: Connection{
+				Name:             "local",
+				BootstrapServers: []string{"a:9092"},
+				Properties:       map[string]string{" client.id ": "djafka", "socket.timeout.ms": "1000"},
+			},
+			want: kafka.ConfigMap{"bootstrap.servers": "a:9092", "client.id": "djafka", "socket.timeout.ms": "1000"},
+		},
+		{
+			name: "properties override security",
+			conn: Connection{
+				Name:             "local",
+				BootstrapServers: []string{"a:9092"},
+				Security:         &Security{CALocation: "ca.pem"},
+				Properties:       map[string]string{"ssl.ca.location": "other.pem"},
+			},
+			want: kafka.ConfigMap{"bootstrap.servers": "a:9092", "security.protocol": ProtocolSSL, "ssl.ca.location": "other.pem"},
+		},
+		{
+			name: "overrides override properties",
+			conn: Connection{
+				Name:             "local",
+				BootstrapServers: []string{"a:9092"},
+				Properties:       map[string]string{"client.id": "djafka", "group.id": "mine"},
+			},
+			overrides: kafka.ConfigMap{"group.id": "inspect"},
+			want:      kafka.ConfigMap{"bootstrap.servers": "a:9092", "client.id": "djafka", "group.id": "inspect"},
+		},
+		{
+			name:      "servers are never overridden",
+			conn:      Connection{Name: "local", BootstrapServers: []string{"a:9092"}},
+			overrides: kafka.ConfigMap{"bootstrap.servers": "b:9092"},
+			want:      kafka.ConfigMap{"bootstrap.servers": "a:9092"},
+		},
+		{
+			name: "no servers",
+			conn: Connection{Name: "local", BootstrapServers: []string{" , "}},
+			err:  "Connection 'local' has no bootstrap servers configured.",
+		},
+		{
+			name: "servers as property",
+			conn: Connection{
+				Name:             "local",
+				BootstrapServers: []string{"a:9092"},
+				Properties:       map[string]string{"bootstrap.servers": "b:9092"},
+			},
+			err: "use 'bootstrapServers' instead",
+		},
+		{
+			name: "empty property name",
+			conn: Connection{
+				Name:             "local",
+				BootstrapServers: []string{"a:9092"},
+				Properties:       map[string]string{" ": "value"},
+			},
+			err: "contains a property with an empty name",
+		},
+		{
+			name: "invalid security",
+			conn: Connection{
+				Name:             "local",
+				BootstrapServers: []string{"a:9092"},
+				Security:         &Security{Protocol: "carrier-pigeon"},
+			},
+			err: "Invalid security settings for connection 'local'",
+		},
+	}
+
+	for _, test := range tests {
+		config, err := test.conn.ClientConfig(test.overrides)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*config, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, *config)
+		}
+	}
+}
+
+// writeConfig writes content to path, creating its directory.
+func writeConfig(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chdir changes the working directory to dir until the test finished.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+func TestReadConfig(t *testing.T) {
+	home, project, other := t.TempDir(), t.TempDir(), t.TempDir()
+	userPath := filepath.Join(home, "djafka", "config.json")
+	projectPath := filepath.Join(project, "config.yaml")
+	otherPath := filepath.Join(other, "config.toml")
+	user := `{"version": 2, "connections": [
+		{"name": "local", "bootstrapServers": ["localhost:9092"]},
+		{"name": "staging", "bootstrapServers": ["staging:9092"]}
+	]}`
+	projectConfig := `version: 2
+connections:
+  - name: staging
+    bootstrapServers: [project-staging:9092]
+  - name: project
+    bootstrapServers: [project:9092]
+`
+	otherConfig := `version = 2
+
+[[connections]]
+name = "other"
+bootstrapServers = ["other:9092"]
+`
+
+	type connection struct {
+		name    string
+		servers string
+		source  ConfigSource
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		path  string
+		env   string
+		want  []connection
+		err   string
+	}{
+		{
+			name: "no config",
+			err:  "Failed to find a config file",
+		},
+		{
+			name:  "user config",
+			files: map[string]string{userPath: user},
+			want: []connection{
+				{"local", "localhost:9092", ConfigSource{SourceUser, userPath}},
+				{"staging", "staging:9092", ConfigSource{SourceUser, userPath}},
+			},
+		},
+		{
+			name:  "project config",
+			files: map[string]string{projectPath: projectConfig},
+			want: []connection{
+				{"staging", "project-staging:9092", ConfigSource{SourceProject, projectPath}},
+				{"project", "project:9092", ConfigSource{SourceProject, projectPath}},
+			},
+		},
+		{
+			name:  "project config overrides user config by name",
+			files: map[string]string{userPath: user, projectPath: projectConfig},
+			want: []connection{
+				{"local", "localhost:9092", ConfigSource{SourceUser, userPath}},
+				{"staging", "project-staging:9092", ConfigSource{SourceProject, projectPath}},
+				{"project", "project:9092", ConfigSource{SourceProject, projectPath}},
+			},
+		},
+		{
+			name: "json is found before yaml",
+			files: map[string]string{
+				filepath.Join(project, "config.json"): `{"version": 2, "connections": [{"name": "json", "bootstrapServers": ["json:9092"]}]}`,
+				projectPath:                           projectConfig,
+			},
+			want: []connection{
+				{"json", "json:9092", ConfigSource{SourceProject, filepath.Join(project, "config.json")}},
+			},
+		},
+		{
+			name:  "environment replaces discovery",
+			files: map[string]string{userPath: user, projectPath: projectConfig, otherPath: otherConfig},
+			env:   otherPath,
+			want: []connection{
+				{"other", "other:9092", ConfigSource{SourceEnv, otherPath}},
+			},
+		},
+		{
+			name:  "flag replaces environment",
+			files: map[string]string{projectPath: projectConfig, otherPath: otherConfig},
+			path:  projectPath,
+			env:   otherPath,
+			want: []connection{
+				{"staging", "project-staging:9092", ConfigSource{SourceFlag, projectPath}},
+				{"project", "project:9092", ConfigSource{SourceFlag, projectPath}},
+			},
+		},
+		{
+			name:  "missing flag file",
+			files: map[string]string{userPath: user},
+			path:  otherPath,
+			err:   "Failed to read config file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, dir := range []string{home, project, other} {
+				entries, err := os.ReadDir(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, entry := range entries {
+					if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			for path, content := range test.files {
+				writeConfig(t, path, content)
+			}
+			t.Setenv("XDG_CONFIG_HOME", home)
+			t.Setenv(ConfigEnv, test.env)
+			chdir(t, project)
+
+			config, err := ReadConfig(test.path)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []connection{}
+			for _, conn := range config.Connections {
+				got = append(got, connection{conn.Name, strings.Join(conn.Servers(), ","), conn.Source})
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
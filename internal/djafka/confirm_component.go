@@ -0,0 +1,60 @@
+package djafka
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ConfirmComponent asks the user to confirm an action. Pressing "y" sends the
+// confirm message, any other key sends a ConfirmCancelMsg.
+type ConfirmComponent struct {
+	Message string
+	Width   int
+	Height  int
+	confirm tea.Msg
+}
+
+func NewConfirmComponent(message string, confirm tea.Msg) ConfirmComponent {
+	return ConfirmComponent{Message: message, confirm: confirm}
+}
+
+func (c ConfirmComponent) Update(msg tea.Msg) (ConfirmComponent, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.Width = msg.Width
+		c.Height = msg.Height
+	case tea.KeyMsg:
+		if msg.String() == "y" {
+			confirm := c.confirm
+			return c, func() tea.Msg { return confirm }
+		}
+		return c, func() tea.Msg { return ConfirmCancelMsg{} }
+	}
+
+	return c, nil
+}
+
+func (c ConfirmComponent) View() string {
+	header := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("69")).
+		Border(lipgloss.NormalBorder(), true).
+		BorderForeground(lipgloss.Color("69")).
+		Width(11).
+		Align(lipgloss.Center).
+		Render("Confirm")
+
+	body := lipgloss.NewStyle().
+		Width(50).
+		Align(lipgloss.Center).
+		Render(c.Message)
+
+	anyKey := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#555555")).
+		Width(50).
+		Align(lipgloss.Center).
+		Render("Press y to confirm or any other key to cancel ...")
+
+	box := lipgloss.JoinVertical(lipgloss.Center, header, body, anyKey)
+
+	return lipgloss.Place(c.Width, c.Height, lipgloss.Center, lipgloss.Center, box)
+}
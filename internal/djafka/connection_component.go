@@ -14,6 +14,7 @@ func changeConnection(conn Connection) tea.Cmd {
 type ConnectionComponent struct {
 	table.Model
 	config *Config
+	health map[string]ClusterHealth
 }
 
 func (c ConnectionComponent) Update(msg tea.Msg) (ConnectionComponent, tea.Cmd) {
@@ -34,3 +35,45 @@ func (c ConnectionComponent) Update(msg tea.Msg) (ConnectionComponent, tea.Cmd)
 
 	return c, cmd
 }
+
+// SetConfig shows the connections of config and moves the cursor to the
+// connection with the given name.
+func (c *ConnectionComponent) SetConfig(config *Config, selected string) {
+	c.config = config
+	c.health = map[string]ClusterHealth{}
+
+	cursor := 0
+	for i, connection := range config.Connections {
+		if connection.Name == selected {
+			cursor = i
+		}
+	}
+
+	c.setRows()
+	c.Model.SetCursor(cursor)
+}
+
+// SetHealth updates the status of the connection with the given name.
+func (c *ConnectionComponent) SetHealth(name string, health ClusterHealth) {
+	c.health[name] = health
+	c.setRows()
+}
+
+func (c *ConnectionComponent) setRows() {
+	rows := []table.Row{}
+	for _, connection := range c.config.Connections {
+		status := "checking ..."
+		if health, ok := c.health[connection.Name]; ok {
+			status = health.Status()
+		}
+		rows = append(rows, table.Row{connection.Name, connection.Source.Kind, status})
+	}
+
+	c.Model.SetRows(rows)
+}
+
+// Selected returns the connection under the cursor.
+func (c *ConnectionComponent) Selected() Connection {
+	conn, _ := c.config.FindConnection(c.SelectedRow()[0])
+	return conn
+}
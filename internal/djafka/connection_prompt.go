@@ -0,0 +1,297 @@
+package djafka
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	connectionNameInput = iota
+	connectionServersInput
+	protocolInput
+	mechanismInput
+	usernameInput
+	passwordInput
+	caLocationInput
+	certificateLocationInput
+	keyLocationInput
+	keyPasswordInput
+	tokenEndpointInput
+	clientIdInput
+	clientSecretInput
+	scopeInput
+)
+
+var connectionInputLabels = [...]string{
+	"Name",
+	"Bootstrap Servers",
+	"Security Protocol",
+	"SASL Mechanism",
+	"Username",
+	"Password",
+	"CA Location",
+	"Certificate Location",
+	"Key Location",
+	"Key Password",
+	"OAuth Token Endpoint",
+	"OAuth Client Id",
+	"OAuth Client Secret",
+	"OAuth Scope",
+}
+
+var connectionInputPlaceholders = []string{
+	"localhost",
+	"localhost:9092,localhost:9093",
+	"PLAINTEXT, SSL, SASL_PLAINTEXT or SASL_SSL",
+	"PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER",
+	"",
+	"env:KAFKA_PASS, file:/path or cmd:command",
+	"/etc/ssl/certs/ca.pem",
+	"/path/to/client.pem",
+	"/path/to/client.key",
+	"env:KAFKA_KEY_PASS, file:/path or cmd:command",
+	"https://auth.example.com/oauth2/token",
+	"",
+	"env:KAFKA_CLIENT_SECRET, file:/path or cmd:command",
+	"",
+}
+
+var (
+	titleStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("69"))
+	statusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ff0000")).Width(80)
+)
+
+type ConnectionPrompt struct {
+	focusIndex int
+	inputs     [len(connectionInputLabels)]textinput.Model
+	cursorMode textinput.CursorMode
+	logger     *log.Logger
+	title      string
+	status     string
+	original   Connection
+	previous   string
+}
+
+// InitialConnectionPrompt creates a prompt to enter the settings of a
+// connection, prefilled with the values of conn. previous is the name of the
+// connection being edited and empty for new connections.
+func InitialConnectionPrompt(log *log.Logger, title string, conn Connection, previous string) ConnectionPrompt {
+	m := ConnectionPrompt{
+		logger:   log,
+		title:    title,
+		original: conn,
+		previous: previous,
+	}
+
+	security := Security{}
+	if conn.Security != nil {
+		security = *conn.Security
+	}
+	values := []string{
+		conn.Name,
+		strings.Join(conn.BootstrapServers, ","),
+		security.Protocol,
+		security.Mechanism,
+		security.Username,
+		security.Password,
+		security.CALocation,
+		security.CertificateLocation,
+		security.KeyLocation,
+		security.KeyPassword,
+		security.TokenEndpoint,
+		security.ClientId,
+		security.ClientSecret,
+		security.Scope,
+	}
+
+	var t textinput.Model
+
+	for i := range m.inputs {
+		t = newTextInput()
+		t.CursorStyle = cursorStyle
+		t.CharLimit = 256
+		t.Placeholder = connectionInputPlaceholders[i]
+		t.SetValue(values[i])
+
+		if i == connectionNameInput {
+			t.Focus()
+			t.PromptStyle = focusedStyle
+			t.TextStyle = focusedStyle
+			t.CharLimit = 64
+		}
+
+		m.inputs[i] = t
+	}
+	m.maskSecrets()
+
+	return m
+}
+
+func (m ConnectionPrompt) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// SetStatus shows a message below the inputs, e.g. the result of a
+// connection check.
+func (m *ConnectionPrompt) SetStatus(status string) {
+	m.status = status
+}
+
+// Previous returns the name of the edited connection, empty for new ones.
+func (m ConnectionPrompt) Previous() string {
+	return m.previous
+}
+
+// Connection returns the connection described by the current input values.
+func (m ConnectionPrompt) Connection() Connection {
+	value := func(i int) string {
+		return strings.TrimSpace(m.inputs[i].Value())
+	}
+
+	conn := m.original
+	conn.Name = value(connectionNameInput)
+	conn.BootstrapServers = []string{}
+	for _, server := range strings.Split(value(connectionServersInput), ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			conn.BootstrapServers = append(conn.BootstrapServers, server)
+		}
+	}
+
+	security := Security{
+		Protocol:            strings.ToUpper(value(protocolInput)),
+		Mechanism:           strings.ToUpper(value(mechanismInput)),
+		Username:            value(usernameInput),
+		Password:            value(passwordInput),
+		CALocation:          value(caLocationInput),
+		CertificateLocation: value(certificateLocationInput),
+		KeyLocation:         value(keyLocationInput),
+		KeyPassword:         value(keyPasswordInput),
+		TokenEndpoint:       value(tokenEndpointInput),
+		ClientId:            value(clientIdInput),
+		ClientSecret:        value(clientSecretInput),
+		Scope:               value(scopeInput),
+	}
+	conn.Security = nil
+	if security != (Security{}) {
+		conn.Security = &security
+	}
+
+	return conn
+}
+
+// maskSecrets hides typed passwords, but shows secret references so they can
+// be checked.
+func (m *ConnectionPrompt) maskSecrets() {
+	for _, i := range []int{passwordInput, keyPasswordInput, clientSecretInput} {
+		m.inputs[i].EchoMode = textinput.EchoPassword
+		if m.inputs[i].Value() == "" || isSecretReference(m.inputs[i].Value()) {
+			m.inputs[i].EchoMode = textinput.EchoNormal
+		}
+	}
+}
+
+func (m ConnectionPrompt) validate(conn Connection) string {
+	if conn.Name == "" || len(conn.BootstrapServers) == 0 {
+		return "Please enter a name and at least one bootstrap server."
+	}
+	if conn.Security != nil {
+		if _, err := conn.Security.properties(); err != nil {
+			return fmt.Sprintf("Invalid security settings: %s.", err)
+		}
+	}
+
+	return ""
+}
+
+func (m ConnectionPrompt) Update(msg tea.Msg) (ConnectionPrompt, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", ESC:
+			return m, func() tea.Msg { return ConnectionPromptCancel{} }
+
+		case "ctrl+s", "enter":
+			conn := m.Connection()
+			if problem := m.validate(conn); problem != "" {
+				m.status = problem
+				return m, nil
+			}
+			res := ConnectionSubmitMsg{conn, msg.String() == "ctrl+s"}
+			m.logger.Println("Submiting connection", conn.Name)
+			return m, func() tea.Msg { return res }
+
+		// Set focus to next input
+		case "tab", "shift+tab", "up", "down":
+			s := msg.String()
+
+			// Cycle indexes
+			if s == "up" || s == "shift+tab" {
+				m.focusIndex--
+			} else {
+				m.focusIndex++
+			}
+
+			if m.focusIndex > len(m.inputs)-1 {
+				m.focusIndex = 0
+			} else if m.focusIndex < 0 {
+				m.focusIndex = len(m.inputs) - 1
+			}
+
+			cmds := make([]tea.Cmd, len(m.inputs))
+			for i := 0; i <= len(m.inputs)-1; i++ {
+				if i == m.focusIndex {
+					// Set focused state
+					cmds[i] = m.inputs[i].Focus()
+					m.inputs[i].PromptStyle = focusedStyle
+					m.inputs[i].TextStyle = focusedStyle
+					continue
+				}
+				// Remove focused state
+				m.inputs[i].Blur()
+				m.inputs[i].PromptStyle = noStyle
+				m.inputs[i].TextStyle = noStyle
+			}
+
+			return m, tea.Batch(cmds...)
+		}
+	}
+
+	// Handle character input and blinking
+	cmd := m.updateInputs(msg)
+	m.maskSecrets()
+
+	return m, cmd
+}
+
+func (m *ConnectionPrompt) updateInputs(msg tea.Msg) tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.inputs))
+
+	// Only text inputs with Focus() set will respond, so it's safe to simply
+	// update all of them here without any further logic.
+	for i := range m.inputs {
+		m.inputs[i], cmds[i] = m.inputs[i].Update(msg)
+	}
+
+	return tea.Batch(cmds...)
+}
+
+func (m ConnectionPrompt) View() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\n\t %s\n\n", titleStyle.Render(m.title))
+	for i, input := range m.inputs {
+		if i == protocolInput {
+			fmt.Fprintf(&b, "\n\t %s\n", helpStyle.Render("Security (optional)"))
+		}
+		fmt.Fprintf(&b, "\t %s\n\t %s\n", inputStyle.Width(30).Render(connectionInputLabels[i]), input.View())
+	}
+	fmt.Fprintf(&b, "\n\t %s\n\n", statusStyle.Render(m.status))
+	fmt.Fprintf(&b, "\t %s\n", helpStyle.Render("enter: test and save • ctrl+s: save without testing • tab: next field • esc: cancel"))
+
+	return b.String()
+}
@@ -2,6 +2,8 @@ package djafka
 
 // Menu and Table Labels
 const ConnectionsLabel = "Connections"
+const SourceLabel = "Source"
+const StatusLabel = "Status"
 const MenuLabel = "Menu"
 const ResultLabel = "Result"
 const DetailsLabel = "Details"
@@ -9,12 +11,16 @@ const DetailsLabel = "Details"
 const TopicsLabel = "Topics"
 const ConsumersLabel = "Consumers"
 const ConsumerGroupsLabel = "Consumer Groups"
+const SchemasLabel = "Schemas"
 const InfoLabel = "Info"
 
 const ConsumerIdLabel = "ConsumerId"
 const GroupIdLabel = "GroupId"
 const StateLabel = "State"
 
+const SubjectLabel = "Subject"
+const CompatibilityLabel = "Compatibility"
+
 // Keystrokes
 
 const ESC = "esc"
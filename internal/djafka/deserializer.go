@@ -0,0 +1,473 @@
+package djafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/quick"
+	"github.com/linkedin/goavro/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// The formats of keys and values, DeserializerFormats lists them in the order
+// they are cycled through in the messages view.
+const (
+	FormatString   = "string"
+	FormatJSON     = "json"
+	FormatHex      = "hex"
+	FormatBase64   = "base64"
+	FormatMsgPack  = "msgpack"
+	FormatAvro     = "avro"
+	FormatProtobuf = "protobuf"
+	FormatRegistry = "registry"
+)
+
+var DeserializerFormats = []string{FormatString, FormatJSON, FormatHex, FormatBase64, FormatMsgPack, FormatAvro, FormatProtobuf, FormatRegistry}
+
+// Decoded is a key or value as shown in the messages view.
+type Decoded struct {
+	// Text is shown in the record view
+	Text string
+	// Line is shown in the list of records
+	Line string
+	// JSON is set if Text is JSON, which is highlighted in the record view
+	JSON bool
+	// Schema names the registry schema the data was decoded with
+	Schema string
+	// Err is why the data could not be deserialized, Text and Line show the
+	// raw data then
+	Err error
+}
+
+// Deserializer turns keys or values of records into text.
+type Deserializer interface {
+	Format() string
+	Deserialize(data []byte) (Decoded, error)
+}
+
+// NewDeserializer returns the deserializer of serde. Relative schema paths
+// are resolved against dir, registry is the schema registry of the connection
+// or nil. If serde is nil, records are decoded with the registry if there is
+// one, else as strings.
+func NewDeserializer(serde *Serde, dir string, registry *SchemaRegistry) (Deserializer, error) {
+	if serde == nil {
+		if registry != nil {
+			return registryDeserializer{registry, stringDeserializer{}}, nil
+		}
+		return stringDeserializer{}, nil
+	}
+
+	switch serde.Format {
+	case "", FormatString:
+		return stringDeserializer{}, nil
+	case FormatJSON:
+		return jsonDeserializer{}, nil
+	case FormatHex:
+		return hexDeserializer{}, nil
+	case FormatBase64:
+		return base64Deserializer{}, nil
+	case FormatMsgPack:
+		return msgpackDeserializer{}, nil
+	case FormatAvro:
+		return newAvroDeserializer(serde.Schema, dir)
+	case FormatProtobuf:
+		if serde.Message == "" {
+			return protobufDeserializer{}, nil
+		}
+		return newProtobufMessageDeserializer(serde.Schema, serde.Message, dir)
+	case FormatRegistry:
+		if registry == nil {
+			return nil, fmt.Errorf("the connection has no schema registry")
+		}
+		return registryDeserializer{registry, stringDeserializer{}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format '%s', expected one of %s", serde.Format, strings.Join(DeserializerFormats, ", "))
+}
+
+// Decode deserializes data, falling back to the raw data with the error if
+// that fails. Missing keys and values are shown empty.
+func Decode(d Deserializer, data []byte) Decoded {
+	if data == nil {
+		return Decoded{}
+	}
+
+	decoded, err := d.Deserialize(data)
+	if err != nil {
+		return Decoded{Text: string(data), Line: string(data), Err: fmt.Errorf("Failed to deserialize as %s: %w", d.Format(), err)}
+	}
+	return decoded
+}
+
+// jsonDecoded returns a value decoded from JSON or another format as indented
+// and compact JSON.
+func jsonDecoded(value any) (Decoded, error) {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return Decoded{}, err
+	}
+	return jsonText(line)
+}
+
+func jsonText(data []byte) (Decoded, error) {
+	var text, line bytes.Buffer
+	if err := json.Indent(&text, data, "", "  "); err != nil {
+		return Decoded{}, err
+	}
+	if err := json.Compact(&line, data); err != nil {
+		return Decoded{}, err
+	}
+	return Decoded{Text: text.String(), Line: line.String(), JSON: true}, nil
+}
+
+// highlightJSON colors JSON for the terminal, or returns it as is if that
+// fails.
+func highlightJSON(text string) string {
+	return highlight(text, "json")
+}
+
+// highlight colors text in the given language for the terminal, or returns
+// it as is if that fails.
+func highlight(text string, language string) string {
+	var b strings.Builder
+	if err := quick.Highlight(&b, text, language, "terminal256", "monokai"); err != nil {
+		return text
+	}
+	return b.String()
+}
+
+type stringDeserializer struct{}
+
+func (stringDeserializer) Format() string { return FormatString }
+
+func (stringDeserializer) Deserialize(data []byte) (Decoded, error) {
+	return Decoded{Text: string(data), Line: string(data)}, nil
+}
+
+type jsonDeserializer struct{}
+
+func (jsonDeserializer) Format() string { return FormatJSON }
+
+func (jsonDeserializer) Deserialize(data []byte) (Decoded, error) {
+	if !json.Valid(data) {
+		return Decoded{}, fmt.Errorf("invalid JSON")
+	}
+	return jsonText(data)
+}
+
+type hexDeserializer struct{}
+
+func (hexDeserializer) Format() string { return FormatHex }
+
+func (hexDeserializer) Deserialize(data []byte) (Decoded, error) {
+	pairs := make([]string, len(data))
+	for i, b := range data {
+		pairs[i] = hex.EncodeToString([]byte{b})
+	}
+	return Decoded{Text: strings.TrimSuffix(hex.Dump(data), "\n"), Line: strings.Join(pairs, " ")}, nil
+}
+
+type base64Deserializer struct{}
+
+func (base64Deserializer) Format() string { return FormatBase64 }
+
+func (base64Deserializer) Deserialize(data []byte) (Decoded, error) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	return Decoded{Text: encoded, Line: encoded}, nil
+}
+
+type msgpackDeserializer struct{}
+
+func (msgpackDeserializer) Format() string { return FormatMsgPack }
+
+func (msgpackDeserializer) Deserialize(data []byte) (Decoded, error) {
+	var value any
+	if err := msgpack.Unmarshal(data, &value); err != nil {
+		return Decoded{}, err
+	}
+	return jsonDecoded(jsonCompatible(value))
+}
+
+// jsonCompatible converts maps with keys other than strings, which msgpack
+// allows, to maps json can encode.
+func jsonCompatible(value any) any {
+	switch v := value.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return m
+	case map[string]any:
+		for key, item := range v {
+			v[key] = jsonCompatible(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = jsonCompatible(item)
+		}
+	}
+	return value
+}
+
+type avroDeserializer struct {
+	codec *goavro.Codec
+}
+
+func newAvroDeserializer(schema string, dir string) (Deserializer, error) {
+	if schema == "" {
+		return nil, fmt.Errorf("the avro format needs a schema file")
+	}
+	if !filepath.IsAbs(schema) {
+		schema = filepath.Join(dir, schema)
+	}
+	content, err := os.ReadFile(schema)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read avro schema: %w", err)
+	}
+	codec, err := goavro.NewCodec(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse avro schema '%s': %w", schema, err)
+	}
+
+	return avroDeserializer{codec}, nil
+}
+
+func (avroDeserializer) Format() string { return FormatAvro }
+
+func (d avroDeserializer) Deserialize(data []byte) (Decoded, error) {
+	return decodeAvro(d.codec, data)
+}
+
+func decodeAvro(codec *goavro.Codec, data []byte) (Decoded, error) {
+	native, rest, err := codec.NativeFromBinary(data)
+	if err != nil {
+		return Decoded{}, err
+	}
+	if len(rest) > 0 {
+		return Decoded{}, fmt.Errorf("%d bytes left after the record", len(rest))
+	}
+	// goavro's textual encoding writes the fields of records in random
+	// order, json sorts them
+	return jsonDecoded(native)
+}
+
+// protobufDeserializer decodes protobuf messages without their schema, like
+// protoc --decode_raw, into JSON objects keyed by field number.
+type protobufDeserializer struct{}
+
+func (protobufDeserializer) Format() string { return FormatProtobuf }
+
+func (protobufDeserializer) Deserialize(data []byte) (Decoded, error) {
+	message, err := decodeRawProtobuf(data)
+	if err != nil {
+		return Decoded{}, err
+	}
+	return jsonDecoded(message)
+}
+
+// protobufMessageDeserializer decodes protobuf messages of a type described
+// by local descriptors.
+type protobufMessageDeserializer struct {
+	message protoreflect.MessageDescriptor
+}
+
+func newProtobufMessageDeserializer(schema string, message string, dir string) (Deserializer, error) {
+	if schema == "" {
+		return nil, fmt.Errorf("the protobuf message type '%s' needs a descriptor set or .proto files as schema", message)
+	}
+	if !filepath.IsAbs(schema) {
+		schema = filepath.Join(dir, schema)
+	}
+	descriptors, err := loadProtoDescriptors(schema)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := descriptors.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to find message type '%s' in '%s': %w", message, schema, err)
+	}
+	md, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("'%s' in '%s' is not a message type", message, schema)
+	}
+
+	return protobufMessageDeserializer{md}, nil
+}
+
+// loadProtoDescriptors compiles the .proto files of a directory, a single
+// .proto file, which may import the files next to it, or reads a
+// FileDescriptorSet like the ones written by protoc --descriptor_set_out or
+// buf build.
+func loadProtoDescriptors(path string) (protodesc.Resolver, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read protobuf schema: %w", err)
+	}
+	if !info.IsDir() && filepath.Ext(path) != ".proto" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read protobuf schema: %w", err)
+		}
+		var set descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(content, &set); err != nil {
+			return nil, fmt.Errorf("Failed to parse descriptor set '%s': %w", path, err)
+		}
+		files, err := protodesc.NewFiles(&set)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse descriptor set '%s', it may lack imports (--include_imports): %w", path, err)
+		}
+		return files, nil
+	}
+
+	root := path
+	if !info.IsDir() {
+		root = filepath.Dir(path)
+	}
+	sources, err := readProtoSources(root)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read .proto files in '%s': %w", root, err)
+	}
+	names := []string{filepath.Base(path)}
+	if info.IsDir() {
+		names = names[:0]
+		for name := range sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("No .proto files in '%s'", root)
+		}
+	}
+	files, err := compileProtos(context.Background(), sources, names...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compile .proto files in '%s': %w", root, err)
+	}
+	return files.AsResolver(), nil
+}
+
+// readProtoSources reads the .proto files below root by their path relative
+// to it, which is how they import each other.
+func readProtoSources(root string) (map[string]string, error) {
+	sources := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".proto" {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		sources[filepath.ToSlash(name)] = string(content)
+		return nil
+	})
+	return sources, err
+}
+
+func (protobufMessageDeserializer) Format() string { return FormatProtobuf }
+
+func (d protobufMessageDeserializer) Deserialize(data []byte) (Decoded, error) {
+	return decodeProtobufMessage(d.message, data)
+}
+
+// decodeProtobufMessage decodes data as a message of type md into JSON.
+func decodeProtobufMessage(md protoreflect.MessageDescriptor, data []byte) (Decoded, error) {
+	message := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(data, message); err != nil {
+		// the protobuf module randomly puts a non-breaking space into its
+		// messages to keep them from being matched, which shouldn't change
+		// how records are shown
+		return Decoded{}, fmt.Errorf("invalid %s: %s", md.FullName(), strings.ReplaceAll(err.Error(), "\u00a0", " "))
+	}
+	text, err := protojson.Marshal(message)
+	if err != nil {
+		return Decoded{}, err
+	}
+	return jsonText(text)
+}
+
+// decodeRawProtobuf decodes a message into a map of field numbers to values,
+// repeated fields to lists. Length delimited fields are decoded as nested
+// messages if possible, else as strings if valid UTF-8, else as bytes.
+func decodeRawProtobuf(data []byte) (map[string]any, error) {
+	message := map[string]any{}
+	for len(data) > 0 {
+		number, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var value any
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			value, data = v, data[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			value, data = v, data[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			value, data = v, data[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			if nested, err := decodeRawProtobuf(v); err == nil && len(v) > 0 {
+				value = nested
+			} else if utf8.Valid(v) {
+				value = string(v)
+			} else {
+				value = v
+			}
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d of field %d", typ, number)
+		}
+
+		key := strconv.Itoa(int(number))
+		if existing, ok := message[key]; ok {
+			if list, ok := existing.([]any); ok {
+				message[key] = append(list, value)
+			} else {
+				message[key] = []any{existing, value}
+			}
+		} else {
+			message[key] = value
+		}
+	}
+
+	return message, nil
+}
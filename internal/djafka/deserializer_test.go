@@ -0,0 +1,142 @@
+package djafka
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protowire"
+	protoapi "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "Order",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "status", "type": "string"}
+	]
+}`
+
+func TestDeserializers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "order.avsc"), []byte(testAvroSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	codec, err := goavro.NewCodec(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	avro, err := codec.BinaryFromNative(nil, map[string]any{"id": int64(42), "status": "paid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, err := msgpack.Marshal(map[string]any{"id": 42, "tags": []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protos := filepath.Join(dir, "protos")
+	if err := os.Mkdir(protos, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{"common.proto": testCommonProto, "order.proto": testOrderProto}
+	for name, source := range sources {
+		if err := os.WriteFile(filepath.Join(protos, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := compileProtos(context.Background(), sources, "common.proto", "order.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range files {
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(file))
+	}
+	descriptors, err := protoapi.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shop.pb"), descriptors, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	order := testOrderMessage()
+	orderJSON := `{"id":"42","total":{"currency":"EUR","cents":"1250"}}`
+
+	var proto []byte
+	proto = protowire.AppendTag(proto, 1, protowire.VarintType)
+	proto = protowire.AppendVarint(proto, 42)
+	proto = protowire.AppendTag(proto, 2, protowire.BytesType)
+	proto = protowire.AppendString(proto, "paid")
+	proto = protowire.AppendTag(proto, 3, protowire.VarintType)
+	proto = protowire.AppendVarint(proto, 1)
+	proto = protowire.AppendTag(proto, 3, protowire.VarintType)
+	proto = protowire.AppendVarint(proto, 2)
+
+	tests := []struct {
+		serde *Serde
+		data  []byte
+		line  string
+		text  string
+		err   string
+	}{
+		{nil, []byte("plain"), "plain", "plain", ""},
+		{&Serde{Format: FormatJSON}, []byte(`{"id": 42, "items": [1]}`), `{"id":42,"items":[1]}`, "{\n  \"id\": 42,\n  \"items\": [\n    1\n  ]\n}", ""},
+		{&Serde{Format: FormatJSON}, []byte("not json"), "not json", "not json", "Failed to deserialize as json: invalid JSON"},
+		{&Serde{Format: FormatHex}, []byte("ab\x00"), "61 62 00", "00000000  61 62 00                                          |ab.|", ""},
+		{&Serde{Format: FormatBase64}, []byte{0xff, 0x00}, "/wA=", "/wA=", ""},
+		{&Serde{Format: FormatMsgPack}, packed, `{"id":42,"tags":["a","b"]}`, "", ""},
+		{&Serde{Format: FormatAvro, Schema: "order.avsc"}, avro, `{"id":42,"status":"paid"}`, "", ""},
+		{&Serde{Format: FormatAvro, Schema: "order.avsc"}, []byte{0x54}, "\x54", "\x54", "Failed to deserialize as avro"},
+		{&Serde{Format: FormatProtobuf}, proto, `{"1":42,"2":"paid","3":[1,2]}`, "", ""},
+		{&Serde{Format: FormatProtobuf, Schema: "protos", Message: "shop.Order"}, order, orderJSON, "", ""},
+		{&Serde{Format: FormatProtobuf, Schema: "protos/order.proto", Message: "shop.Order"}, order, orderJSON, "", ""},
+		{&Serde{Format: FormatProtobuf, Schema: "shop.pb", Message: "shop.Order"}, order, orderJSON, "", ""},
+		{&Serde{Format: FormatProtobuf, Schema: "shop.pb", Message: "shop.Order"}, []byte{0x08}, "\x08", "\x08", "Failed to deserialize as protobuf: invalid shop.Order"},
+	}
+
+	for _, test := range tests {
+		d, err := NewDeserializer(test.serde, dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := Decode(d, test.data)
+		if test.err != "" {
+			if decoded.Err == nil || !strings.HasPrefix(decoded.Err.Error(), test.err) {
+				t.Errorf("%s of %q: got error %v, want %s", d.Format(), test.data, decoded.Err, test.err)
+			}
+		} else if decoded.Err != nil {
+			t.Errorf("%s of %q: %s", d.Format(), test.data, decoded.Err)
+		}
+		if decoded.Line != test.line {
+			t.Errorf("%s of %q: got line %q, want %q", d.Format(), test.data, decoded.Line, test.line)
+		}
+		if test.text != "" && decoded.Text != test.text {
+			t.Errorf("%s of %q: got text %q, want %q", d.Format(), test.data, decoded.Text, test.text)
+		}
+	}
+
+	if _, err := NewDeserializer(&Serde{Format: FormatAvro}, dir, nil); err == nil {
+		t.Error("avro without a schema: expected an error")
+	}
+	for _, serde := range []Serde{
+		{Format: FormatProtobuf, Message: "shop.Order"},
+		{Format: FormatProtobuf, Schema: "protos", Message: "shop.Missing"},
+		{Format: FormatProtobuf, Schema: "shop.pb", Message: "shop"},
+		{Format: FormatProtobuf, Schema: "missing.pb", Message: "shop.Order"},
+		{Format: FormatProtobuf, Schema: "order.avsc", Message: "shop.Order"},
+	} {
+		if _, err := NewDeserializer(&serde, dir, nil); err == nil {
+			t.Errorf("%+v: expected an error", serde)
+		}
+	}
+	if _, err := NewDeserializer(&Serde{Format: "xml"}, dir, nil); err == nil {
+		t.Error("unknown format: expected an error")
+	}
+}
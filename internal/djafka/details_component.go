@@ -32,6 +32,15 @@ func (c *DetailsComponent) SetConsumerDetails(item Consumer) {
 	c.Model.SetRows(rows)
 }
 
+func (c *DetailsComponent) SetSubjectVersions(versions []int) {
+	rows := []table.Row{}
+	for i := len(versions) - 1; i >= 0; i-- {
+		rows = append(rows, table.Row{strconv.Itoa(versions[i])})
+	}
+
+	c.Model.SetRows(rows)
+}
+
 func (c *DetailsComponent) SetTopicDetails(item TopicConfig) {
 	rows := []table.Row{}
 	for key, value := range item.Settings {
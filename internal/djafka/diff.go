@@ -0,0 +1,106 @@
+package djafka
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("114"))
+)
+
+// diffLine is a line of a side by side diff. Lines only on one side leave the
+// other one empty.
+type diffLine struct {
+	left, right    string
+	removed, added bool
+}
+
+// diffLines compares two texts line by line using their longest common
+// subsequence. Removed lines are paired with the lines added in their place.
+func diffLines(a string, b string) []diffLine {
+	left, right := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// common[i][j] is the length of the longest common subsequence of
+	// left[i:] and right[j:]
+	common := make([][]int, len(left)+1)
+	for i := range common {
+		common[i] = make([]int, len(right)+1)
+	}
+	for i := len(left) - 1; i >= 0; i-- {
+		for j := len(right) - 1; j >= 0; j-- {
+			if left[i] == right[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	lines := []diffLine{}
+	removed, added := []string{}, []string{}
+	// flush pairs the removed and added lines of a change
+	flush := func() {
+		for k := 0; k < len(removed) || k < len(added); k++ {
+			line := diffLine{}
+			if k < len(removed) {
+				line.left, line.removed = removed[k], true
+			}
+			if k < len(added) {
+				line.right, line.added = added[k], true
+			}
+			lines = append(lines, line)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+
+	i, j := 0, 0
+	for i < len(left) || j < len(right) {
+		switch {
+		case i < len(left) && j < len(right) && left[i] == right[j]:
+			flush()
+			lines = append(lines, diffLine{left: left[i], right: right[j]})
+			i++
+			j++
+		case j >= len(right) || i < len(left) && common[i+1][j] >= common[i][j+1]:
+			removed = append(removed, left[i])
+			i++
+		default:
+			added = append(added, right[j])
+			j++
+		}
+	}
+	flush()
+
+	return lines
+}
+
+// renderDiff renders lines in two columns of width each, removed lines red
+// on the left and added lines green on the right.
+func renderDiff(lines []diffLine, width int) string {
+	column := lipgloss.NewStyle().Width(width).MaxWidth(width)
+
+	rows := make([]string, len(lines))
+	for i, line := range lines {
+		left, right := column.Render(line.left), column.Render(line.right)
+		marker := "   "
+		if line.removed {
+			left = removedStyle.Render(left)
+			marker = " < "
+		}
+		if line.added {
+			right = addedStyle.Render(right)
+			marker = " > "
+		}
+		if line.removed && line.added {
+			marker = " | "
+		}
+		rows[i] = left + marker + right
+	}
+
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,36 @@
+package djafka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []diffLine
+	}{
+		{"a\nb", "a\nb", []diffLine{{left: "a", right: "a"}, {left: "b", right: "b"}}},
+		{"a\nb\nc", "a\nx\nc", []diffLine{
+			{left: "a", right: "a"},
+			{left: "b", right: "x", removed: true, added: true},
+			{left: "c", right: "c"},
+		}},
+		{"a\nc", "a\nb\nc", []diffLine{
+			{left: "a", right: "a"},
+			{right: "b", added: true},
+			{left: "c", right: "c"},
+		}},
+		{"a\nb\nc", "c", []diffLine{
+			{left: "a", removed: true},
+			{left: "b", removed: true},
+			{left: "c", right: "c"},
+		}},
+	}
+
+	for _, test := range tests {
+		if got := diffLines(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("diffLines(%q, %q) = %+v, want %+v", test.a, test.b, got, test.want)
+		}
+	}
+}
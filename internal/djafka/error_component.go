@@ -7,8 +7,10 @@ import (
 
 type ErrorComponent struct {
 	Message string
-	Width   int
-	Height  int
+	// Hint replaces the default hint to press any key
+	Hint   string
+	Width  int
+	Height int
 }
 
 func (c ErrorComponent) Update(msg tea.Msg) (ErrorComponent, tea.Cmd) {
@@ -35,11 +37,15 @@ func (c ErrorComponent) View() string {
 		Align(lipgloss.Center).
 		Render(c.Message)
 
+	hint := c.Hint
+	if hint == "" {
+		hint = "Press any key to continue ..."
+	}
 	anyKey := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#555555")).
 		Width(50).
 		Align(lipgloss.Center).
-		Render("Press any key to continue ...")
+		Render(hint)
 
 	box := lipgloss.JoinVertical(lipgloss.Center, header, body, anyKey)
 
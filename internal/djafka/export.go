@@ -0,0 +1,321 @@
+package djafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ExportFormat is the format of a file records are exported to.
+type ExportFormat string
+
+const (
+	// ExportJSONLines writes a JSON object per record. Keys, values and header
+	// values which are JSON objects or arrays are written as is, everything
+	// else as a string, so binary data is not preserved.
+	ExportJSONLines ExportFormat = "jsonl"
+	// ExportCSV writes the columns topic, partition, offset, timestamp, key,
+	// value and headers like key=value,other=value.
+	ExportCSV ExportFormat = "csv"
+	// ExportBase64 writes JSON Lines with base64 keys, values and header
+	// values, which preserves binary data.
+	ExportBase64 ExportFormat = base64Encoding
+)
+
+// exportTimestampLayout keeps the milliseconds of record timestamps.
+const exportTimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// ParseExportFormat returns the format named by format, or the format of path
+// by its extension if format is empty.
+func ParseExportFormat(format string, path string) (ExportFormat, error) {
+	if format == "" {
+		if strings.ToLower(filepath.Ext(path)) == ".csv" {
+			return ExportCSV, nil
+		}
+		return ExportJSONLines, nil
+	}
+
+	switch ExportFormat(strings.ToLower(format)) {
+	case ExportJSONLines, "json":
+		return ExportJSONLines, nil
+	case ExportCSV:
+		return ExportCSV, nil
+	case ExportBase64, "binary":
+		return ExportBase64, nil
+	}
+
+	return "", fmt.Errorf("Unknown format '%s', expected jsonl, csv or base64.", format)
+}
+
+// ExportRange selects the records to export.
+type ExportRange struct {
+	// Partitions are the partitions to export, all if empty
+	Partitions []int32
+	Start      StartPosition
+	// End is the position after the last record to export
+	End StartPosition
+}
+
+func (r ExportRange) String() string {
+	partitions := "all partitions"
+	if len(r.Partitions) > 0 {
+		ids := make([]string, 0, len(r.Partitions))
+		for _, partition := range r.Partitions {
+			ids = append(ids, strconv.Itoa(int(partition)))
+		}
+		partitions = fmt.Sprintf("partitions %s", strings.Join(ids, ","))
+	}
+
+	end := "until " + strings.TrimPrefix(r.End.String(), "from ")
+	if r.End.Mode == SeekTimestamp {
+		end = "before " + r.End.Timestamp.Format(timestampLayout)
+	}
+
+	return fmt.Sprintf("%s %s %s", partitions, r.Start, end)
+}
+
+// ParseExportRange parses the partitions like "0,2", or all if empty, and the
+// start and end of the records to export. Both are either offsets like "42" or
+// "0:42,1:17", or times like for ParseStartPosition. The records start at the
+// beginning and end at the current end of the partitions if not given.
+func ParseExportRange(partitions string, start string, end string) (ExportRange, error) {
+	r := ExportRange{Start: StartPosition{Mode: SeekEarliest}, End: StartPosition{Mode: SeekLatest}}
+
+	if partitions = strings.TrimSpace(partitions); partitions != "" && partitions != "all" {
+		for _, item := range strings.Split(partitions, ",") {
+			p, err := strconv.ParseInt(strings.TrimSpace(item), 10, 32)
+			if err != nil || p < 0 {
+				return r, fmt.Errorf("'%s' is not a valid partition", strings.TrimSpace(item))
+			}
+			r.Partitions = append(r.Partitions, int32(p))
+		}
+	}
+
+	var err error
+	if strings.TrimSpace(start) != "" {
+		if r.Start, err = parseRangeBound(start); err != nil {
+			return r, err
+		}
+	}
+	if strings.TrimSpace(end) != "" {
+		if r.End, err = parseRangeBound(end); err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+func parseRangeBound(input string) (StartPosition, error) {
+	if position, err := ParseStartPosition(SeekOffset, input); err == nil {
+		return position, nil
+	}
+	if position, err := ParseStartPosition(SeekTimestamp, input); err == nil {
+		return position, nil
+	}
+
+	return StartPosition{}, fmt.Errorf("'%s' is neither an offset like 42 or 0:42,1:17 nor a time like '%s'", strings.TrimSpace(input), timestampLayout)
+}
+
+// recordWriter writes records to a file in an export format.
+type recordWriter interface {
+	Write(record Record) error
+	Flush() error
+}
+
+func newRecordWriter(w io.Writer, format ExportFormat) (recordWriter, error) {
+	buffered := bufio.NewWriter(w)
+
+	switch format {
+	case ExportCSV:
+		writer := csv.NewWriter(buffered)
+		err := writer.Write([]string{"topic", "partition", "offset", "timestamp", "key", "value", "headers"})
+		return &csvRecordWriter{writer, buffered}, err
+	case ExportBase64:
+		return newJSONRecordWriter(buffered, base64Field, base64Encoding), nil
+	default:
+		return newJSONRecordWriter(buffered, textField, ""), nil
+	}
+}
+
+// exportedRecord is a line of the JSON Lines formats, which ReadRecords reads
+// back.
+type exportedRecord struct {
+	Topic     string           `json:"topic"`
+	Partition int32            `json:"partition"`
+	Offset    int64            `json:"offset"`
+	Timestamp int64            `json:"timestamp"`
+	Key       json.RawMessage  `json:"key"`
+	Value     json.RawMessage  `json:"value"`
+	Headers   []exportedHeader `json:"headers"`
+	Encoding  string           `json:"encoding,omitempty"`
+}
+
+type exportedHeader struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type jsonRecordWriter struct {
+	encoder  *json.Encoder
+	buffered *bufio.Writer
+	field    func(value []byte) json.RawMessage
+	encoding string
+}
+
+func newJSONRecordWriter(buffered *bufio.Writer, field func(value []byte) json.RawMessage, encoding string) *jsonRecordWriter {
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+	return &jsonRecordWriter{encoder, buffered, field, encoding}
+}
+
+func (w *jsonRecordWriter) Write(record Record) error {
+	headers := make([]exportedHeader, 0, len(record.Headers))
+	for _, header := range record.Headers {
+		headers = append(headers, exportedHeader{header.Key, w.field(header.Value)})
+	}
+
+	return w.encoder.Encode(exportedRecord{
+		Topic:     record.Topic,
+		Partition: record.Partition,
+		Offset:    record.Offset,
+		Timestamp: record.Timestamp.UnixMilli(),
+		Key:       w.field(record.Key),
+		Value:     w.field(record.Value),
+		Headers:   headers,
+		Encoding:  w.encoding,
+	})
+}
+
+func (w *jsonRecordWriter) Flush() error {
+	return w.buffered.Flush()
+}
+
+// textField returns JSON objects and arrays as is and everything else as a
+// string.
+func textField(value []byte) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	trimmed := strings.TrimSpace(string(value))
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid(value) {
+		return value
+	}
+
+	s, _ := json.Marshal(string(value))
+	return s
+}
+
+func base64Field(value []byte) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+
+	return json.RawMessage(strconv.Quote(base64.StdEncoding.EncodeToString(value)))
+}
+
+type csvRecordWriter struct {
+	writer   *csv.Writer
+	buffered *bufio.Writer
+}
+
+func (w *csvRecordWriter) Write(record Record) error {
+	headers := make([]string, 0, len(record.Headers))
+	for _, header := range record.Headers {
+		headers = append(headers, fmt.Sprintf("%s=%s", header.Key, header.Value))
+	}
+
+	return w.writer.Write([]string{
+		record.Topic,
+		strconv.Itoa(int(record.Partition)),
+		strconv.FormatInt(record.Offset, 10),
+		record.Timestamp.Format(exportTimestampLayout),
+		string(record.Key),
+		string(record.Value),
+		strings.Join(headers, ","),
+	})
+}
+
+func (w *csvRecordWriter) Flush() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return err
+	}
+	return w.buffered.Flush()
+}
+
+// ExportRecords writes the records of topic within r to w in format as they
+// are read, so they are never held in memory all at once. It returns the
+// number of records written, progress is called with it after every record.
+func ExportRecords(ctx context.Context, backend KafkaBackend, topic string, r ExportRange, format ExportFormat, w io.Writer, progress func(written int)) (int, error) {
+	writer, err := newRecordWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// records is unbuffered, so every record was received once done is sent
+	records := make(chan Record)
+	done := make(chan error, 1)
+	go func() {
+		done <- backend.ReadRange(ctx, topic, r.Partitions, r.Start, r.End, records)
+	}()
+
+	written := 0
+	for {
+		select {
+		case record := <-records:
+			if err := writer.Write(record); err != nil {
+				return written, err
+			}
+			written++
+			if progress != nil {
+				progress(written)
+			}
+		case err := <-done:
+			if err != nil {
+				return written, err
+			}
+			if err := ctx.Err(); err != nil {
+				return written, err
+			}
+			return written, writer.Flush()
+		}
+	}
+}
+
+// ExportFile exports the records of topic within r to a new file at path. An
+// existing file is never overwritten, the new file is removed if the export
+// fails.
+func ExportFile(ctx context.Context, backend KafkaBackend, topic string, r ExportRange, format ExportFormat, path string, progress func(written int)) (int, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return 0, fmt.Errorf("File '%s' already exists, choose another one.", path)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create file '%s': %w", path, err)
+	}
+
+	written, err := ExportRecords(ctx, backend, topic, r, format, f, progress)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return written, fmt.Errorf("Failed to export topic '%s' to '%s': %w", topic, path, err)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,251 @@
+package djafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	exportFileInput = iota
+	exportFormatInput
+	exportPartitionsInput
+	exportStartInput
+	exportEndInput
+)
+
+var exportInputLabels = [...]string{"File", "Format", "Partitions", "From", "To"}
+
+var exportInputPlaceholders = []string{
+	"path of the file to write",
+	"jsonl, csv or base64 (default: by extension)",
+	"like 0,2 (default: all)",
+	"offset like 42 or 0:42,1:17, or a time (default: beginning)",
+	"offset or time, not included (default: current end)",
+}
+
+// ExportProgress is the number of records written by an export so far.
+type ExportProgress struct {
+	Written int
+	Done    bool
+	Err     error
+}
+
+// exportRun runs ExportFile in the background. Its progress is read by the
+// commands returned from next.
+type exportRun struct {
+	// updates holds the latest progress only
+	updates chan ExportProgress
+	cancel  context.CancelFunc
+}
+
+func startExportRun(ctx context.Context, backend KafkaBackend, topic string, r ExportRange, format ExportFormat, path string) *exportRun {
+	ctx, cancel := context.WithCancel(ctx)
+	run := &exportRun{updates: make(chan ExportProgress, 1), cancel: cancel}
+	go func() {
+		written, err := ExportFile(ctx, backend, topic, r, format, path, func(written int) {
+			run.report(ExportProgress{Written: written})
+		})
+		run.report(ExportProgress{Written: written, Done: true, Err: err})
+	}()
+
+	return run
+}
+
+// report replaces the progress not read yet. Progress is reported from a
+// single goroutine, so the send never blocks.
+func (r *exportRun) report(p ExportProgress) {
+	select {
+	case <-r.updates:
+	default:
+	}
+	r.updates <- p
+}
+
+func (r *exportRun) next() tea.Cmd {
+	return func() tea.Msg {
+		return ExportProgressMsg{r, <-r.updates}
+	}
+}
+
+// ExportPrompt is the prompt to export the records of a topic to a file,
+// showing the number of records written while they are read.
+type ExportPrompt struct {
+	focusIndex int
+	inputs     [len(exportInputLabels)]textinput.Model
+	logger     *log.Logger
+	topic      string
+	status     string
+
+	// ctx and backend are kept to start a run
+	ctx     context.Context
+	backend KafkaBackend
+	run     *exportRun
+}
+
+func InitialExportPrompt(log *log.Logger, topic string, ctx context.Context, backend KafkaBackend) ExportPrompt {
+	m := ExportPrompt{
+		logger:  log,
+		topic:   topic,
+		ctx:     ctx,
+		backend: backend,
+	}
+
+	var t textinput.Model
+
+	for i := range m.inputs {
+		t = newTextInput()
+		t.CursorStyle = cursorStyle
+		t.CharLimit = 1024
+		t.Placeholder = exportInputPlaceholders[i]
+
+		if i == exportFileInput {
+			t.Focus()
+			t.PromptStyle = focusedStyle
+			t.TextStyle = focusedStyle
+		}
+
+		m.inputs[i] = t
+	}
+
+	return m
+}
+
+func (m ExportPrompt) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Stop cancels the export, which removes the file written so far.
+func (m *ExportPrompt) Stop() {
+	if m.run != nil {
+		m.run.cancel()
+	}
+}
+
+// start validates the inputs and starts writing the file.
+func (m *ExportPrompt) start() tea.Cmd {
+	path := strings.TrimSpace(m.inputs[exportFileInput].Value())
+	if path == "" {
+		m.status = "Enter the file to write."
+		return nil
+	}
+
+	format, err := ParseExportFormat(strings.TrimSpace(m.inputs[exportFormatInput].Value()), path)
+	if err != nil {
+		m.status = err.Error()
+		return nil
+	}
+
+	r, err := ParseExportRange(
+		m.inputs[exportPartitionsInput].Value(),
+		m.inputs[exportStartInput].Value(),
+		m.inputs[exportEndInput].Value(),
+	)
+	if err != nil {
+		m.status = fmt.Sprintf("Invalid range: %s", err)
+		return nil
+	}
+
+	m.logger.Printf("Exporting %s of %s to %s", r, m.topic, path)
+	m.status = fmt.Sprintf("Exporting %s ...", r)
+	m.run = startExportRun(m.ctx, m.backend, m.topic, r, format, path)
+	return m.run.next()
+}
+
+func (m ExportPrompt) Update(msg tea.Msg) (ExportPrompt, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ExportProgressMsg:
+		if msg.run != m.run {
+			return m, nil
+		}
+		if !msg.progress.Done {
+			m.status = fmt.Sprintf("Exporting ... %d records written", msg.progress.Written)
+			return m, m.run.next()
+		}
+		m.run = nil
+		if msg.progress.Err != nil {
+			m.status = msg.progress.Err.Error()
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Exported %d records to %s.", msg.progress.Written, strings.TrimSpace(m.inputs[exportFileInput].Value()))
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", ESC:
+			return m, func() tea.Msg { return ExportCancel{} }
+
+		case "enter":
+			if m.run != nil {
+				return m, nil
+			}
+			if m.focusIndex < len(m.inputs)-1 {
+				m.focusIndex++
+				return m, m.focus()
+			}
+			return m, m.start()
+
+		// Set focus to next input
+		case "tab", "shift+tab", "up", "down":
+			s := msg.String()
+
+			if s == "up" || s == "shift+tab" {
+				m.focusIndex--
+			} else {
+				m.focusIndex++
+			}
+
+			if m.focusIndex >= len(m.inputs) {
+				m.focusIndex = 0
+			} else if m.focusIndex < 0 {
+				m.focusIndex = len(m.inputs) - 1
+			}
+
+			return m, m.focus()
+		}
+	}
+
+	// Handle character input and blinking
+	cmds := make([]tea.Cmd, len(m.inputs))
+	for i := range m.inputs {
+		m.inputs[i], cmds[i] = m.inputs[i].Update(msg)
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m *ExportPrompt) focus() tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.inputs))
+	for i := range m.inputs {
+		if i == m.focusIndex {
+			// Set focused state
+			cmds[i] = m.inputs[i].Focus()
+			m.inputs[i].PromptStyle = focusedStyle
+			m.inputs[i].TextStyle = focusedStyle
+			continue
+		}
+		// Remove focused state
+		m.inputs[i].Blur()
+		m.inputs[i].PromptStyle = noStyle
+		m.inputs[i].TextStyle = noStyle
+	}
+
+	return tea.Batch(cmds...)
+}
+
+func (m ExportPrompt) View() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\n\t %s\n\n", titleStyle.Render(fmt.Sprintf("Export %s", m.topic)))
+	for i, input := range m.inputs {
+		fmt.Fprintf(&b, "\t %s\n\t %s\n", inputStyle.Width(30).Render(exportInputLabels[i]), input.View())
+	}
+	fmt.Fprintf(&b, "\n\t %s\n\n", statusStyle.Render(m.status))
+	fmt.Fprintf(&b, "\t %s\n", helpStyle.Render("enter: next field / export • tab: next field • esc: cancel and close"))
+
+	return b.String()
+}
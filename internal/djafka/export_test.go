@@ -0,0 +1,106 @@
+package djafka
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func exportCluster(t *testing.T) KafkaBackend {
+	cluster := testCluster(t)
+	produceAt(t, cluster, testTime.Add(time.Minute), 0, "order-2", `{"id":2}`)
+	produceAt(t, cluster, testTime.Add(2*time.Minute), 0, "order-3", "\x00\xffbinary")
+	produceAt(t, cluster, testTime.Add(3*time.Minute), 1, "order-4", `{"id":4}`, kafka.Header{Key: "trace", Value: []byte("abc")})
+
+	backend, err := cluster.Connect(testConfig().Connections[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func TestExportRange(t *testing.T) {
+	backend := exportCluster(t)
+
+	tests := []struct {
+		partitions, start, end string
+		want                   []string
+	}{
+		{"", "", "", []string{"order-1", "order-2", "order-3", "order-4"}},
+		{"0", "1", "", []string{"order-2", "order-3"}},
+		{"0", "", "2", []string{"order-1", "order-2"}},
+		{"", "2023-06-01 12:31:00", "2023-06-01 12:33:00", []string{"order-2", "order-3"}},
+		{"1", "0:5,1:0", "", []string{"order-4"}},
+		{"", "0:1", "", []string{"order-2", "order-3"}},
+		{"", "", "0:1,1:5", []string{"order-1", "order-4"}},
+	}
+
+	for _, test := range tests {
+		r, err := ParseExportRange(test.partitions, test.start, test.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if _, err := ExportRecords(context.Background(), backend, "orders", r, ExportCSV, &b, nil); err != nil {
+			t.Fatal(err)
+		}
+		records, err := ReadRecords(&b, BulkCSV, "orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := []string{}
+		for _, record := range records {
+			keys = append(keys, string(record.Key))
+		}
+		if !reflect.DeepEqual(keys, test.want) {
+			t.Errorf("%s: expected %v, got %v", r, test.want, keys)
+		}
+	}
+
+	if _, err := ParseExportRange("a", "", ""); err == nil {
+		t.Error("expected an error for partition 'a'")
+	}
+	if _, err := ParseExportRange("", "yesterday", ""); err == nil {
+		t.Error("expected an error for start 'yesterday'")
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	backend := exportCluster(t)
+	r := ExportRange{Start: StartPosition{Mode: SeekEarliest}, End: StartPosition{Mode: SeekLatest}}
+
+	var jsonl bytes.Buffer
+	written, err := ExportRecords(context.Background(), backend, "orders", ExportRange{Partitions: []int32{1}, Start: r.Start, End: r.End}, ExportJSONLines, &jsonl, nil)
+	if err != nil || written != 1 {
+		t.Fatal(written, err)
+	}
+	want := `{"topic":"orders","partition":1,"offset":0,"timestamp":1685622780000,"key":"order-4","value":{"id":4},"headers":[{"key":"trace","value":"abc"}]}` + "\n"
+	if jsonl.String() != want {
+		t.Errorf("expected %s, got %s", want, jsonl.String())
+	}
+
+	// base64 keeps binary values, which are read back as they were
+	var b bytes.Buffer
+	if _, err := ExportRecords(context.Background(), backend, "orders", r, ExportBase64, &b, nil); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadRecords(&b, BulkJSONLines, "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+	binary := records[2]
+	if string(binary.Value) != "\x00\xffbinary" || binary.Partition != 0 || !binary.Timestamp.Equal(testTime.Add(2*time.Minute)) {
+		t.Errorf("binary record changed: %+v", binary)
+	}
+	if headers := records[3].Headers; len(headers) != 1 || string(headers[0].Value) != "abc" {
+		t.Errorf("headers changed: %+v", headers)
+	}
+}
@@ -0,0 +1,606 @@
+package djafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// defaultFakeTopicConfig is the config every topic of a FakeCluster starts
+// with.
+var defaultFakeTopicConfig = map[string]string{
+	"cleanup.policy":      "delete",
+	"retention.ms":        "604800000",
+	"min.insync.replicas": "1",
+}
+
+// FakeCluster is an in-memory cluster, so the TUI can be driven and tested
+// without a broker. Its Connect method is a BackendFactory, all backends
+// created by it share the same data.
+type FakeCluster struct {
+	mu      sync.Mutex
+	brokers int
+	topics  map[string]*fakeTopic
+	groups  map[string]*fakeGroup
+	// unreachable fails every request when set
+	unreachable error
+	// produced is closed and replaced whenever a message is produced, to wake
+	// up fetching backends
+	produced chan struct{}
+	// now timestamps messages produced without timestamp
+	now func() time.Time
+}
+
+type fakeTopic struct {
+	config     map[string]string
+	partitions [][]kafka.Message
+	// next is the partition of the next message without key
+	next int
+}
+
+type fakeGroup struct {
+	state   string
+	members []FakeMember
+	offsets map[string]map[int32]int64
+}
+
+// FakeMember is a member of a consumer group of a FakeCluster, which is
+// assigned all partitions of its topics.
+type FakeMember struct {
+	ConsumerId string
+	Topics     []string
+}
+
+func NewFakeCluster() *FakeCluster {
+	return &FakeCluster{
+		brokers:  1,
+		topics:   map[string]*fakeTopic{},
+		groups:   map[string]*fakeGroup{},
+		produced: make(chan struct{}),
+		now:      time.Now,
+	}
+}
+
+// SetClock replaces the clock used to timestamp messages produced without
+// timestamp.
+func (f *FakeCluster) SetClock(now func() time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = now
+}
+
+// Connect returns a new backend of the cluster, ignoring the settings of conn.
+func (f *FakeCluster) Connect(conn Connection, logger *log.Logger) (KafkaBackend, error) {
+	return &fakeBackend{cluster: f, conn: conn, done: make(chan struct{})}, nil
+}
+
+// SetUnreachable makes every request fail with err, or succeed again when err
+// is nil.
+func (f *FakeCluster) SetUnreachable(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.unreachable = err
+}
+
+// AddTopic creates a topic with the given partition count, its config is
+// merged into the default config.
+func (f *FakeCluster) AddTopic(name string, partitions int, config map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.topics[name]; ok {
+		return kafka.NewError(kafka.ErrTopicAlreadyExists, fmt.Sprintf("Topic '%s' already exists.", name), false)
+	}
+	if partitions < 1 {
+		return kafka.NewError(kafka.ErrInvalidPartitions, "Number of partitions must be larger than 0.", false)
+	}
+
+	settings := map[string]string{}
+	for key, value := range defaultFakeTopicConfig {
+		settings[key] = value
+	}
+	for key, value := range config {
+		settings[key] = value
+	}
+	f.topics[name] = &fakeTopic{config: settings, partitions: make([][]kafka.Message, partitions)}
+
+	return nil
+}
+
+// Produce appends msg to its topic partition. Messages for kafka.PartitionAny
+// are partitioned by key, or round robin without key.
+func (f *FakeCluster) Produce(msg kafka.Message) (kafka.TopicPartition, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if msg.TopicPartition.Topic == nil {
+		return kafka.TopicPartition{}, kafka.NewError(kafka.ErrInvalidArg, "Message has no topic.", false)
+	}
+	name := *msg.TopicPartition.Topic
+	topic, ok := f.topics[name]
+	if !ok {
+		return kafka.TopicPartition{}, unknownTopicError(name)
+	}
+
+	partition := msg.TopicPartition.Partition
+	if partition == kafka.PartitionAny {
+		if len(msg.Key) > 0 {
+			hash := fnv.New32a()
+			hash.Write(msg.Key)
+			partition = int32(hash.Sum32() % uint32(len(topic.partitions)))
+		} else {
+			partition = int32(topic.next % len(topic.partitions))
+			topic.next++
+		}
+	}
+	if partition < 0 || int(partition) >= len(topic.partitions) {
+		return kafka.TopicPartition{}, kafka.NewError(kafka.ErrUnknownPartition, fmt.Sprintf("Topic '%s' has no partition %d.", name, partition), false)
+	}
+
+	msg.TopicPartition = kafka.TopicPartition{
+		Topic:     &name,
+		Partition: partition,
+		Offset:    kafka.Offset(len(topic.partitions[partition])),
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = f.now()
+		msg.TimestampType = kafka.TimestampCreateTime
+	}
+	topic.partitions[partition] = append(topic.partitions[partition], msg)
+
+	close(f.produced)
+	f.produced = make(chan struct{})
+
+	return msg.TopicPartition, nil
+}
+
+// AddConsumerGroup creates or replaces a consumer group with the given state,
+// e.g. "Stable" or "Empty", and members.
+func (f *FakeCluster) AddConsumerGroup(group string, state string, members ...FakeMember) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	offsets := map[string]map[int32]int64{}
+	if existing, ok := f.groups[group]; ok {
+		offsets = existing.offsets
+	}
+	f.groups[group] = &fakeGroup{state: state, members: members, offsets: offsets}
+}
+
+// CommitOffset sets the committed offset of a consumer group, creating an
+// empty group if needed.
+func (f *FakeCluster) CommitOffset(group string, topic string, partition int32, offset int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.commit(group, topic, partition, offset)
+}
+
+func (f *FakeCluster) commit(group string, topic string, partition int32, offset int64) {
+	g, ok := f.groups[group]
+	if !ok {
+		g = &fakeGroup{state: "Empty", offsets: map[string]map[int32]int64{}}
+		f.groups[group] = g
+	}
+	if g.offsets[topic] == nil {
+		g.offsets[topic] = map[int32]int64{}
+	}
+	g.offsets[topic][partition] = offset
+}
+
+// CommittedOffset returns the committed offset of a consumer group.
+func (f *FakeCluster) CommittedOffset(group string, topic string, partition int32) (int64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, ok := f.groups[group]
+	if !ok {
+		return 0, false
+	}
+	offset, ok := g.offsets[topic][partition]
+	return offset, ok
+}
+
+func unknownTopicError(name string) error {
+	return kafka.NewError(kafka.ErrUnknownTopicOrPart, fmt.Sprintf("Topic '%s' does not exist.", name), false)
+}
+
+// fakeBackend is a client of a FakeCluster, which can be closed independent
+// of the others.
+type fakeBackend struct {
+	cluster   *FakeCluster
+	conn      Connection
+	mu        sync.Mutex
+	closed    bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ KafkaBackend = (*fakeBackend)(nil)
+
+// lock must be called before every request, it fails like a real client
+// would and returns with the cluster locked.
+func (b *fakeBackend) lock(ctx context.Context) error {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return ErrServiceClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.cluster.mu.Lock()
+	if err := b.cluster.unreachable; err != nil {
+		b.cluster.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (b *fakeBackend) unlock() {
+	b.cluster.mu.Unlock()
+}
+
+func (b *fakeBackend) Close() {
+	b.closeOnce.Do(func() { close(b.done) })
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+}
+
+func (b *fakeBackend) Health(ctx context.Context) ClusterHealth {
+	if err := b.lock(ctx); err != nil {
+		return ClusterHealth{Err: err}
+	}
+	defer b.unlock()
+
+	return ClusterHealth{Reachable: true, Brokers: b.cluster.brokers, Controller: 1}
+}
+
+func (b *fakeBackend) ListTopics(ctx context.Context) ([]Topic, error) {
+	if err := b.lock(ctx); err != nil {
+		return nil, fmt.Errorf("Failed to fetch meta data: %w", err)
+	}
+	defer b.unlock()
+
+	topics := []Topic{}
+	for name, topic := range b.cluster.topics {
+		topics = append(topics, Topic{name, len(topic.partitions)})
+	}
+
+	sort.Slice(topics, func(i, j int) bool {
+		return topics[i].Name < topics[j].Name
+	})
+
+	return topics, nil
+}
+
+func (b *fakeBackend) CreateTopic(ctx context.Context, name string, partitions int, replicationFactor int) (Topic, error) {
+	if err := b.lock(ctx); err != nil {
+		return Topic{}, fmt.Errorf("Failed to create new topic '%s': %w", name, err)
+	}
+	brokers := b.cluster.brokers
+	b.unlock()
+
+	if replicationFactor > brokers {
+		return Topic{}, fmt.Errorf("Failed to create new topic: %w", kafka.NewError(kafka.ErrInvalidReplicationFactor,
+			fmt.Sprintf("Replication factor: %d larger than available brokers: %d.", replicationFactor, brokers), false))
+	}
+	if err := b.cluster.AddTopic(name, partitions, nil); err != nil {
+		return Topic{}, fmt.Errorf("Failed to create new topic: %w", err)
+	}
+
+	return Topic{name, partitions}, nil
+}
+
+func (b *fakeBackend) GetTopicConfig(ctx context.Context, name string) (TopicConfig, error) {
+	if err := b.lock(ctx); err != nil {
+		return TopicConfig{}, fmt.Errorf("Failed to config from topic '%s': %w", name, err)
+	}
+	defer b.unlock()
+
+	topic, ok := b.cluster.topics[name]
+	if !ok {
+		return TopicConfig{}, fmt.Errorf("Failed to config from topic '%s': %w", name, unknownTopicError(name))
+	}
+
+	settings := map[string]string{}
+	for key, value := range topic.config {
+		settings[key] = value
+	}
+
+	return TopicConfig{name, settings}, nil
+}
+
+func (b *fakeBackend) GetTopicMetadata(ctx context.Context, name string) (kafka.TopicMetadata, error) {
+	if err := b.lock(ctx); err != nil {
+		return kafka.TopicMetadata{}, fmt.Errorf("Failed to get metadata of topic '%s': %w", name, err)
+	}
+	defer b.unlock()
+
+	return b.topicMetadata(name)
+}
+
+func (b *fakeBackend) topicMetadata(name string) (kafka.TopicMetadata, error) {
+	topic, ok := b.cluster.topics[name]
+	if !ok {
+		return kafka.TopicMetadata{}, fmt.Errorf("Failed to get metadata of topic '%s': %w", name, unknownTopicError(name))
+	}
+
+	metadata := kafka.TopicMetadata{Topic: name}
+	for i := range topic.partitions {
+		metadata.Partitions = append(metadata.Partitions, kafka.PartitionMetadata{
+			ID:       int32(i),
+			Leader:   1,
+			Replicas: []int32{1},
+			Isrs:     []int32{1},
+		})
+	}
+
+	return metadata, nil
+}
+
+func (b *fakeBackend) ListConsumerGroups(ctx context.Context) ([]string, error) {
+	if err := b.lock(ctx); err != nil {
+		return nil, fmt.Errorf("Failed to list consumer groups: %w", err)
+	}
+	defer b.unlock()
+
+	groupIds := []string{}
+	for group := range b.cluster.groups {
+		if isInspectionGroup(group) {
+			continue
+		}
+		groupIds = append(groupIds, group)
+	}
+
+	sort.StringSlice(groupIds).Sort()
+
+	return groupIds, nil
+}
+
+// ListConsumers describes the groups like Service does, with the id and the
+// partitions of the last member of each group.
+func (b *fakeBackend) ListConsumers(ctx context.Context, groupIds []string) ([]Consumer, error) {
+	if err := b.lock(ctx); err != nil {
+		return nil, fmt.Errorf("Failed to describe consumer groups: %w", err)
+	}
+	defer b.unlock()
+
+	consumers := []Consumer{}
+	for _, groupId := range groupIds {
+		group, ok := b.cluster.groups[groupId]
+		if !ok {
+			consumers = append(consumers, Consumer{groupId, "", kafka.ConsumerGroupStateDead.String(), nil})
+			continue
+		}
+
+		consumer := Consumer{groupId, "", group.state, nil}
+		for _, member := range group.members {
+			ctp := []ConsumerTopicPartition{}
+			for _, name := range member.Topics {
+				topic, ok := b.cluster.topics[name]
+				if !ok {
+					continue
+				}
+				for partition := range topic.partitions {
+					offset, ok := group.offsets[name][int32(partition)]
+					if !ok {
+						offset = int64(kafka.OffsetInvalid)
+					}
+					ctp = append(ctp, ConsumerTopicPartition{name, kafka.Offset(offset).String(), int32(partition)})
+				}
+			}
+
+			consumer.ConsumerId = member.ConsumerId
+			consumer.TopicPartitions = ctp
+		}
+		consumers = append(consumers, consumer)
+	}
+
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].ConsumerId < consumers[j].ConsumerId
+	})
+
+	return consumers, nil
+}
+
+func (b *fakeBackend) ResetConsumerOffsets(ctx context.Context, group string, topic string, offset int64) error {
+	if err := b.lock(ctx); err != nil {
+		return fmt.Errorf("Failed to alter consumer group offset: %w", err)
+	}
+	defer b.unlock()
+
+	metadata, err := b.topicMetadata(topic)
+	if err != nil {
+		return err
+	}
+	if g, ok := b.cluster.groups[group]; ok && len(g.members) > 0 {
+		return fmt.Errorf("Failed to alter consumer group offset for partition '%d': %s",
+			metadata.Partitions[0].ID, kafka.NewError(kafka.ErrUnknownMemberID, "Consumer group is not empty.", false))
+	}
+
+	for _, partition := range metadata.Partitions {
+		b.cluster.commit(group, topic, partition.ID, offset)
+	}
+
+	return nil
+}
+
+func (b *fakeBackend) Produce(ctx context.Context, record Record) (Record, error) {
+	if err := b.lock(ctx); err != nil {
+		return Record{}, fmt.Errorf("Failed to produce to topic '%s': %w", record.Topic, err)
+	}
+	b.unlock()
+
+	tp, err := b.cluster.Produce(kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &record.Topic, Partition: record.Partition},
+		Key:            record.Key,
+		Value:          record.Value,
+		Headers:        record.Headers,
+		Timestamp:      record.Timestamp,
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("Failed to produce to topic '%s': %w", record.Topic, err)
+	}
+
+	record.Partition = tp.Partition
+	record.Offset = int64(tp.Offset)
+	return record, nil
+}
+
+func (b *fakeBackend) FetchMessages(ctx context.Context, topic string, start StartPosition, records chan<- Record) error {
+	var positions []int
+	for {
+		if err := b.lock(ctx); err != nil {
+			if ctx.Err() != nil || errors.Is(err, ErrServiceClosed) {
+				return nil
+			}
+			return err
+		}
+		t, ok := b.cluster.topics[topic]
+		if !ok {
+			b.unlock()
+			return fmt.Errorf("Failed to subscribe to topic '%s': %w", topic, unknownTopicError(topic))
+		}
+		if positions == nil {
+			if partition, ok := start.unknownPartition(len(t.partitions)); start.Mode == SeekOffset && ok {
+				b.unlock()
+				return fmt.Errorf("Topic '%s' has no partition %d.", topic, partition)
+			}
+			positions = startPositions(t, start)
+		}
+		pending := []Record{}
+		for partition, messages := range t.partitions {
+			if positions[partition] < 0 {
+				continue
+			}
+			for i := range messages[positions[partition]:] {
+				pending = append(pending, newRecord(&messages[positions[partition]+i]))
+			}
+			positions[partition] = len(messages)
+		}
+		produced := b.cluster.produced
+		b.unlock()
+
+		for _, record := range pending {
+			select {
+			case records <- record:
+			case <-ctx.Done():
+				return nil
+			case <-b.done:
+				return nil
+			}
+		}
+
+		select {
+		case <-produced:
+		case <-ctx.Done():
+			return nil
+		case <-b.done:
+			return nil
+		}
+	}
+}
+
+func (b *fakeBackend) ReadRange(ctx context.Context, topic string, partitions []int32, start StartPosition, end StartPosition, records chan<- Record) error {
+	if err := b.lock(ctx); err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrServiceClosed) {
+			return nil
+		}
+		return err
+	}
+	t, ok := b.cluster.topics[topic]
+	if !ok {
+		b.unlock()
+		return fmt.Errorf("Failed to subscribe to topic '%s': %w", topic, unknownTopicError(topic))
+	}
+	if len(partitions) == 0 {
+		for partition := range t.partitions {
+			partitions = append(partitions, int32(partition))
+		}
+	}
+	for _, position := range []StartPosition{start, end} {
+		if partition, ok := position.unknownPartition(len(t.partitions)); position.Mode == SeekOffset && ok {
+			b.unlock()
+			return fmt.Errorf("Topic '%s' has no partition %d.", topic, partition)
+		}
+	}
+	starts, ends := startPositions(t, start), startPositions(t, end)
+	pending := []Record{}
+	for _, partition := range partitions {
+		if partition < 0 || int(partition) >= len(t.partitions) {
+			b.unlock()
+			return fmt.Errorf("Topic '%s' has no partition %d.", topic, partition)
+		}
+		messages := t.partitions[partition]
+		if starts[partition] < 0 {
+			continue
+		}
+		for i := starts[partition]; i < ends[partition]; i++ {
+			pending = append(pending, newRecord(&messages[i]))
+		}
+	}
+	b.unlock()
+
+	for _, record := range pending {
+		select {
+		case records <- record:
+		case <-ctx.Done():
+			return nil
+		case <-b.done:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// startPositions resolves start to the index of the first message to read of
+// every partition of t, -1 for partitions which are not read.
+func startPositions(t *fakeTopic, start StartPosition) []int {
+	positions := make([]int, len(t.partitions))
+	for partition, messages := range t.partitions {
+		position := 0
+		switch start.Mode {
+		case SeekLatest:
+			position = len(messages)
+		case SeekOffset:
+			offset, ok := start.offset(int32(partition))
+			if !ok {
+				positions[partition] = -1
+				continue
+			}
+			position = int(offset)
+		case SeekLastN:
+			position = len(messages) - int(start.Count)
+		case SeekTimestamp:
+			position = sort.Search(len(messages), func(i int) bool {
+				return !messages[i].Timestamp.Before(start.Timestamp)
+			})
+		}
+
+		if position < 0 {
+			position = 0
+		} else if position > len(messages) {
+			position = len(messages)
+		}
+		positions[partition] = position
+	}
+
+	return positions
+}
@@ -0,0 +1,347 @@
+package djafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter matches records by a query of one or more conditions joined by
+// "&&" outside of double quotes, all of which must match:
+//   - text: the value contains text
+//   - key:text, value:text: the key or value contains text
+//   - header:name=text: a header called name contains text, header:name: a
+//     header called name exists
+//   - /regexp/ instead of text: the regular expression matches
+//   - $.path: the value is JSON and has a field at path, like $.items[0].id or
+//     $.items[*].id
+//   - $.path op literal: a field at path compares to a JSON literal with one
+//     of ==, !=, <, <=, >, >=, or matches a regular expression with =~
+type Filter struct {
+	query      string
+	conditions []func(Record) bool
+}
+
+// ParseFilter parses a query, see Filter.
+func ParseFilter(query string) (Filter, error) {
+	filter := Filter{query: strings.TrimSpace(query)}
+	if filter.query == "" {
+		return filter, fmt.Errorf("the query is empty")
+	}
+
+	for _, term := range splitConditions(filter.query) {
+		condition, err := parseCondition(strings.TrimSpace(term))
+		if err != nil {
+			return filter, err
+		}
+		filter.conditions = append(filter.conditions, condition)
+	}
+
+	return filter, nil
+}
+
+// splitConditions splits query at every "&&" which is not part of a double
+// quoted string, like a JSON literal or a field of a JSON path.
+func splitConditions(query string) []string {
+	terms := []string{}
+	start, quoted, escaped := 0, false, false
+	for i := 0; i < len(query); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && query[i] == '\\':
+			escaped = true
+		case query[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(query[i:], "&&"):
+			terms = append(terms, query[start:i])
+			start = i + 2
+			i++
+		}
+	}
+
+	return append(terms, query[start:])
+}
+
+// Match reports whether record matches all conditions of the filter.
+func (f Filter) Match(record Record) bool {
+	for _, condition := range f.conditions {
+		if !condition(record) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filter) String() string {
+	return f.query
+}
+
+func parseCondition(term string) (func(Record) bool, error) {
+	if term == "" {
+		return nil, fmt.Errorf("a condition is empty")
+	}
+
+	if strings.HasPrefix(term, "$") {
+		return parseJSONPathCondition(term)
+	}
+
+	if rest, ok := strings.CutPrefix(term, "key:"); ok {
+		match, err := parseTextMatch(rest)
+		return func(r Record) bool { return r.Key != nil && match(r.Key) }, err
+	}
+	if rest, ok := strings.CutPrefix(term, "value:"); ok {
+		match, err := parseTextMatch(rest)
+		return func(r Record) bool { return match(r.Value) }, err
+	}
+	if rest, ok := strings.CutPrefix(term, "header:"); ok {
+		name, text, hasText := strings.Cut(rest, "=")
+		if name == "" {
+			return nil, fmt.Errorf("'%s' names no header", term)
+		}
+		match := func([]byte) bool { return true }
+		if hasText {
+			var err error
+			if match, err = parseTextMatch(text); err != nil {
+				return nil, err
+			}
+		}
+		return func(r Record) bool {
+			for _, header := range r.Headers {
+				if header.Key == name && match(header.Value) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	match, err := parseTextMatch(term)
+	return func(r Record) bool { return match(r.Value) }, err
+}
+
+// parseTextMatch returns a match of a regular expression like /order-\d+/ or
+// of a substring.
+func parseTextMatch(text string) (func([]byte) bool, error) {
+	if len(text) >= 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/") {
+		re, err := regexp.Compile(text[1 : len(text)-1])
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid regular expression: %w", text, err)
+		}
+		return re.Match, nil
+	}
+
+	needle := []byte(text)
+	return func(value []byte) bool { return bytes.Contains(value, needle) }, nil
+}
+
+// jsonPathOperators are ordered so no operator is a prefix of a later one.
+var jsonPathOperators = []string{"==", "!=", "=~", "<=", ">=", "<", ">"}
+
+func parseJSONPathCondition(term string) (func(Record) bool, error) {
+	path, operator, operand := term, "", ""
+	for i := range term {
+		for _, op := range jsonPathOperators {
+			if strings.HasPrefix(term[i:], op) {
+				path, operator, operand = strings.TrimSpace(term[:i]), op, strings.TrimSpace(term[i+len(op):])
+				break
+			}
+		}
+		if operator != "" {
+			break
+		}
+	}
+
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var compare func(value any) bool
+	switch operator {
+	case "":
+		compare = func(any) bool { return true }
+	case "=~":
+		if len(operand) < 2 || !strings.HasPrefix(operand, "/") || !strings.HasSuffix(operand, "/") {
+			return nil, fmt.Errorf("'%s' is no regular expression like /order-\\d+/", operand)
+		}
+		match, err := parseTextMatch(operand)
+		if err != nil {
+			return nil, err
+		}
+		compare = func(value any) bool {
+			s, ok := value.(string)
+			if !ok {
+				s = string(mustMarshal(value))
+			}
+			return match([]byte(s))
+		}
+	default:
+		var literal any
+		if err := json.Unmarshal([]byte(operand), &literal); err != nil {
+			return nil, fmt.Errorf("'%s' is not a JSON literal like \"paid\", 42 or true", operand)
+		}
+		compare = func(value any) bool {
+			return compareJSON(value, operator, literal)
+		}
+	}
+
+	return func(r Record) bool {
+		var document any
+		if err := json.Unmarshal(r.Value, &document); err != nil {
+			return false
+		}
+		for _, value := range evalJSONPath(document, steps) {
+			if compare(value) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// jsonPathStep is a field name, an index, or all elements of an array with
+// index -1.
+type jsonPathStep struct {
+	field string
+	index int
+}
+
+// parseJSONPath parses paths like $.items[0].id, $.items[*].id or $["a b"].
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a JSON path like $.status", path)
+	}
+
+	steps := []jsonPathStep{}
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("'%s' has an empty field name", path)
+			}
+			steps = append(steps, jsonPathStep{field: rest[:end]})
+			rest = rest[end:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("'%s' misses a ]", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if inner == "*" {
+				steps = append(steps, jsonPathStep{index: -1})
+			} else if field, err := strconv.Unquote(inner); err == nil {
+				steps = append(steps, jsonPathStep{field: field})
+			} else if index, err := strconv.Atoi(inner); err == nil && index >= 0 {
+				steps = append(steps, jsonPathStep{index: index})
+			} else {
+				return nil, fmt.Errorf("'[%s]' in '%s' is neither an index, * nor a quoted field", inner, path)
+			}
+		default:
+			return nil, fmt.Errorf("'%s' is not a JSON path like $.status", path)
+		}
+	}
+
+	return steps, nil
+}
+
+// evalJSONPath returns the values at the path given by steps.
+func evalJSONPath(document any, steps []jsonPathStep) []any {
+	values := []any{document}
+	for _, step := range steps {
+		next := []any{}
+		for _, value := range values {
+			switch v := value.(type) {
+			case map[string]any:
+				if step.field == "" {
+					continue
+				}
+				if field, ok := v[step.field]; ok {
+					next = append(next, field)
+				}
+			case []any:
+				if step.field != "" {
+					continue
+				}
+				if step.index < 0 {
+					next = append(next, v...)
+				} else if step.index < len(v) {
+					next = append(next, v[step.index])
+				}
+			}
+		}
+		values = next
+	}
+
+	return values
+}
+
+// compareJSON compares numbers numerically, strings lexically and any other
+// values for equality only.
+func compareJSON(value any, operator string, literal any) bool {
+	var order int
+	switch l := literal.(type) {
+	case float64:
+		v, ok := value.(float64)
+		if !ok {
+			return operator == "!="
+		}
+		order = compareOrdered(v, l)
+	case string:
+		v, ok := value.(string)
+		if !ok {
+			return operator == "!="
+		}
+		order = strings.Compare(v, l)
+	default:
+		equal := bytes.Equal(mustMarshal(value), mustMarshal(literal))
+		switch operator {
+		case "==":
+			return equal
+		case "!=":
+			return !equal
+		}
+		return false
+	}
+
+	switch operator {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func compareOrdered(a float64, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// mustMarshal returns the JSON of a value decoded from JSON.
+func mustMarshal(value any) []byte {
+	b, _ := json.Marshal(value)
+	return b
+}
@@ -0,0 +1,124 @@
+package djafka
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestFilter(t *testing.T) {
+	record := Record{
+		Key:     []byte("order-42"),
+		Value:   []byte(`{"id":42,"status":"paid","items":[{"sku":"a","qty":2},{"sku":"b","qty":5}],"note":null,"terms":"cash && card"}`),
+		Headers: []kafka.Header{{Key: "trace", Value: []byte("abc-123")}},
+	}
+
+	tests := []struct {
+		query string
+		match bool
+	}{
+		{"paid", true},
+		{"refunded", false},
+		{"/\"id\":\\d+/", true},
+		{"key:order-42", true},
+		{"key:/^order-4\\d$/", true},
+		{"key:order-7", false},
+		{"value:sku", true},
+		{"header:trace", true},
+		{"header:trace=abc", true},
+		{"header:trace=/^abc-\\d+$/", true},
+		{"header:span", false},
+		{"$.status", true},
+		{"$.missing", false},
+		{`$.status == "paid"`, true},
+		{`$.status != "paid"`, false},
+		{"$.id >= 42", true},
+		{"$.id > 42", false},
+		{"$.items[1].qty == 5", true},
+		{"$.items[*].sku == \"b\"", true},
+		{"$.items[*].qty > 10", false},
+		{`$["status"] =~ /^pa/`, true},
+		{"$.note == null", true},
+		{"key:order && $.id < 100", true},
+		{"key:order && $.id < 10", false},
+		{`$.terms == "cash && card" && key:order`, true},
+		{`$.terms == "cash && cards"`, false},
+		{`$["terms"] == "cash && card" && $.id == 42`, true},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.query)
+		if err != nil {
+			t.Errorf("%s: %s", test.query, err)
+			continue
+		}
+		if match := filter.Match(record); match != test.match {
+			t.Errorf("%s: expected %t, got %t", test.query, test.match, match)
+		}
+	}
+
+	for _, query := range []string{"", "key:/(/", "$.", "$.id == paid", "$.id =~ paid", "$items", "a && "} {
+		if _, err := ParseFilter(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	cluster := testCluster(t)
+	for i, partition := range []int32{0, 1, 2, 1, 2} {
+		produce(t, cluster, partition, "order-"+string(rune('a'+i)), `{"status":"paid"}`)
+	}
+	backend, err := cluster.Connect(testConfig().Connections[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	filter, err := ParseFilter(`$.status == "paid"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := func(r ExportRange) ([]string, int64) {
+		t.Helper()
+		matches := make(chan Record)
+		done := make(chan error, 1)
+		var scanned atomic.Int64
+		go func() {
+			done <- Search(context.Background(), backend, "orders", r, filter, matches, &scanned)
+		}()
+
+		keys := []string{}
+		for {
+			select {
+			case record := <-matches:
+				keys = append(keys, string(record.Key))
+				continue
+			case err := <-done:
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			break
+		}
+		sort.Strings(keys)
+		return keys, scanned.Load()
+	}
+
+	keys, scanned := search(ExportRange{End: StartPosition{Mode: SeekLatest}})
+	if len(keys) != 5 || keys[0] != "order-a" || scanned != 6 {
+		t.Errorf("expected 5 matches of 6 records, got %v of %d", keys, scanned)
+	}
+	keys, scanned = search(ExportRange{Partitions: []int32{1}, End: StartPosition{Mode: SeekLatest}})
+	if !reflect.DeepEqual(keys, []string{"order-b", "order-d"}) || scanned != 2 {
+		t.Errorf("expected the matches of partition 1, got %v of %d", keys, scanned)
+	}
+	keys, _ = search(ExportRange{Start: StartPosition{Mode: SeekOffset, Offsets: map[int32]int64{2: 1}}, End: StartPosition{Mode: SeekLatest}})
+	if !reflect.DeepEqual(keys, []string{"order-e"}) {
+		t.Errorf("expected the matches of partition 2 from offset 1, got %v", keys)
+	}
+}
@@ -0,0 +1,174 @@
+package djafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	healthInterval = 15 * time.Second
+	healthTimeout  = 3 * time.Second
+	// healthMaxBackoff is the longest time a failed connect is not retried
+	healthMaxBackoff = 10 * time.Minute
+)
+
+// ClusterHealth is the result of probing the cluster of a connection.
+type ClusterHealth struct {
+	Reachable  bool
+	Brokers    int
+	Controller int32
+	Latency    time.Duration
+	Err        error
+}
+
+// Status returns a short summary of the health for the connections table.
+func (h ClusterHealth) Status() string {
+	if !h.Reachable {
+		return fmt.Sprintf("down: %s", h.Err)
+	}
+
+	return fmt.Sprintf("up %dms · %db · ctl %d", h.Latency.Milliseconds(), h.Brokers, h.Controller)
+}
+
+// HealthProbe checks the reachability of clusters in the background. Clusters
+// with a live session are probed with its backend, for the others the probe
+// keeps one backend per connection, so secrets are only resolved once. A failed
+// connect is retried with a growing delay, as resolving the secrets again may
+// run commands.
+type HealthProbe struct {
+	mu       sync.Mutex
+	backends map[string]KafkaBackend
+	failures map[string]connectFailure
+	connect  BackendFactory
+	sessions *SessionManager
+	logger   *log.Logger
+	now      func() time.Time
+}
+
+// connectFailure is the last failed connect of a connection, which is not
+// retried before retry.
+type connectFailure struct {
+	err   error
+	delay time.Duration
+	retry time.Time
+}
+
+func NewHealthProbe(logger *log.Logger, connect BackendFactory, sessions *SessionManager) *HealthProbe {
+	return &HealthProbe{
+		backends: map[string]KafkaBackend{},
+		failures: map[string]connectFailure{},
+		connect:  connect,
+		sessions: sessions,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+func (p *HealthProbe) backend(conn Connection) (KafkaBackend, error) {
+	if backend, ok := p.sessions.Connected(conn.Name); ok {
+		p.drop(conn.Name)
+		return backend, nil
+	}
+
+	p.mu.Lock()
+	backend, ok := p.backends[conn.Name]
+	failure, failed := p.failures[conn.Name]
+	p.mu.Unlock()
+	if ok {
+		return backend, nil
+	}
+	if failed && p.now().Before(failure.retry) {
+		return nil, failure.err
+	}
+
+	// connecting may take a while, probes of other clusters must not wait
+	backend, err := p.connect(conn, p.logger)
+	if err != nil {
+		delay := 2 * failure.delay
+		if delay < healthInterval {
+			delay = healthInterval
+		} else if delay > healthMaxBackoff {
+			delay = healthMaxBackoff
+		}
+		p.mu.Lock()
+		p.failures[conn.Name] = connectFailure{err, delay, p.now().Add(delay)}
+		p.mu.Unlock()
+		return nil, err
+	}
+
+	p.mu.Lock()
+	delete(p.failures, conn.Name)
+	existing, ok := p.backends[conn.Name]
+	if !ok {
+		p.backends[conn.Name] = backend
+	}
+	p.mu.Unlock()
+	if ok {
+		// another probe connected meanwhile
+		go backend.Close()
+		return existing, nil
+	}
+
+	return backend, nil
+}
+
+// drop closes the own backend of the connection with the given name once its
+// running probes finished, e.g. because the connection has a session now.
+func (p *HealthProbe) drop(name string) {
+	p.mu.Lock()
+	backend, ok := p.backends[name]
+	delete(p.backends, name)
+	p.mu.Unlock()
+
+	if ok {
+		go func() {
+			backend.Close()
+			p.logger.Println("Closed health probe backend of", name)
+		}()
+	}
+}
+
+// Probe checks the health of the cluster of conn.
+func (p *HealthProbe) Probe(conn Connection) ClusterHealth {
+	backend, err := p.backend(conn)
+	if err != nil {
+		return ClusterHealth{Err: err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+
+	return backend.Health(ctx)
+}
+
+// Reset closes all backends once their running probes finished and forgets
+// failed connects, e.g. after connections were edited.
+func (p *HealthProbe) Reset() {
+	p.mu.Lock()
+	backends := p.backends
+	p.backends = map[string]KafkaBackend{}
+	p.failures = map[string]connectFailure{}
+	p.mu.Unlock()
+
+	for name, backend := range backends {
+		go func(name string, backend KafkaBackend) {
+			backend.Close()
+			p.logger.Println("Closed health probe backend of", name)
+		}(name, backend)
+	}
+}
+
+// Close closes all backends, waiting for running probes.
+func (p *HealthProbe) Close() {
+	p.mu.Lock()
+	backends := p.backends
+	p.backends = map[string]KafkaBackend{}
+	p.mu.Unlock()
+
+	for _, backend := range backends {
+		backend.Close()
+	}
+}
@@ -0,0 +1,81 @@
+package djafka
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthProbeBackends(t *testing.T) {
+	cluster := NewFakeCluster()
+	var connects atomic.Int32
+	connect := func(conn Connection, logger *log.Logger) (KafkaBackend, error) {
+		connects.Add(1)
+		return cluster.Connect(conn, logger)
+	}
+	logger := log.New(io.Discard, "", 0)
+	sessions := NewSessionManager(logger, connect)
+	probe := NewHealthProbe(logger, connect, sessions)
+	defer sessions.Close()
+	defer probe.Close()
+
+	local, staging := Connection{Name: "local"}, Connection{Name: "staging"}
+	if _, err := sessions.Backend(local); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		for _, conn := range []Connection{local, staging} {
+			if health := probe.Probe(conn); !health.Reachable {
+				t.Fatalf("Probe of %s failed: %v", conn.Name, health.Err)
+			}
+		}
+	}
+
+	if got := connects.Load(); got != 2 {
+		t.Errorf("Connected %d times, want once for the session and once for staging", got)
+	}
+}
+
+func TestHealthProbeBackoff(t *testing.T) {
+	var connects atomic.Int32
+	connect := func(conn Connection, logger *log.Logger) (KafkaBackend, error) {
+		connects.Add(1)
+		return nil, errors.New("unreachable")
+	}
+	logger := log.New(io.Discard, "", 0)
+	sessions := NewSessionManager(logger, connect)
+	probe := NewHealthProbe(logger, connect, sessions)
+	defer sessions.Close()
+	defer probe.Close()
+
+	now := time.Now()
+	probe.now = func() time.Time { return now }
+	conn := Connection{Name: "local"}
+	for _, step := range []struct {
+		elapsed  time.Duration
+		connects int32
+	}{
+		{0, 1},
+		{healthInterval - time.Second, 1},
+		{healthInterval, 2},
+		{2*healthInterval - time.Second, 2},
+		{2 * healthInterval, 3},
+	} {
+		now = now.Add(step.elapsed)
+		if health := probe.Probe(conn); health.Reachable || health.Err == nil {
+			t.Fatalf("Expected the probe to fail, got %+v", health)
+		}
+		if got := connects.Load(); got != step.connects {
+			t.Fatalf("Connected %d times after %s, want %d", got, step.elapsed, step.connects)
+		}
+	}
+
+	probe.Reset()
+	probe.Probe(conn)
+	if got := connects.Load(); got != 4 {
+		t.Errorf("Expected a reset to retry at once, connected %d times", got)
+	}
+}
@@ -15,6 +15,17 @@ type keyMap struct {
 	New   key.Binding
 	Reset key.Binding
 	Quit  key.Binding
+
+	Messages    key.Binding
+	Produce     key.Binding
+	ProduceFile key.Binding
+	Export      key.Binding
+	Schema      key.Binding
+
+	AddConnection    key.Binding
+	EditConnection   key.Binding
+	CloneConnection  key.Binding
+	DeleteConnection key.Binding
 }
 
 var defaultKeys = keyMap{
@@ -42,6 +53,42 @@ var defaultKeys = keyMap{
 		key.WithKeys("ctrl+o", "o"),
 		key.WithHelp("ctrl+o", "reset offset"),
 	),
+	Messages: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "browse messages"),
+	),
+	Produce: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "produce message"),
+	),
+	ProduceFile: key.NewBinding(
+		key.WithKeys("f"),
+		key.WithHelp("f", "produce file"),
+	),
+	Export: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "export to file"),
+	),
+	Schema: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "open schema"),
+	),
+	AddConnection: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "new connection"),
+	),
+	EditConnection: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "edit connection"),
+	),
+	CloneConnection: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "clone connection"),
+	),
+	DeleteConnection: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "delete connection"),
+	),
 	Help: key.NewBinding(
 		key.WithKeys("?"),
 		key.WithHelp("?", "toggle help"),
@@ -70,8 +117,9 @@ func (k keyMap) ShortHelp() []key.Binding {
 
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Up, k.Down, k.Left, k.Right}, // first column
-		{k.New, k.Reset},                // second column
-		{k.Help, k.Quit},                // third column
+		{k.Up, k.Down, k.Left, k.Right},                                            // first column
+		{k.New, k.Reset, k.Messages, k.Produce, k.ProduceFile, k.Export, k.Schema}, // second column
+		{k.AddConnection, k.EditConnection, k.CloneConnection, k.DeleteConnection}, // third column
+		{k.Help, k.Quit}, // fourth column
 	}
 }
@@ -17,6 +17,12 @@ func selectConsumers() tea.Cmd {
 	}
 }
 
+func selectSchemas() tea.Cmd {
+	return func() tea.Msg {
+		return SchemasSelectedMsg{}
+	}
+}
+
 func selectInfo() tea.Cmd {
 	return func() tea.Msg {
 		return InfoSelectedMsg{}
@@ -41,6 +47,8 @@ func (m Menu) Update(msg tea.Msg) (Menu, tea.Cmd) {
 			return m, tea.Batch(cmd, selectTopics())
 		} else if currentRow == ConsumerGroupsLabel {
 			return m, tea.Batch(cmd, selectConsumers())
+		} else if currentRow == SchemasLabel {
+			return m, tea.Batch(cmd, selectSchemas())
 		} else if currentRow == InfoLabel {
 			return m, tea.Batch(cmd, selectInfo())
 		}
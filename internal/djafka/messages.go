@@ -1,7 +1,14 @@
 package djafka
 
 type ConnectionChangedMsg Connection
-type ClientConnectedMsg struct{}
+type ClientConnectedMsg struct {
+	name    string
+	backend KafkaBackend
+}
+type ConnectFailedMsg struct {
+	name string
+	err  error
+}
 
 type TopicsSelectedMsg struct{}
 type TopicsLoadedMsg []Topic
@@ -12,9 +19,72 @@ type ConsumersSelectedMsg struct{}
 type ConsumersLoadedMsg []Consumer
 type ConsumerSelectedMsg Consumer
 
+type RecordsMsg struct {
+	stream  *recordStream
+	records []Record
+}
+type RecordsDoneMsg struct {
+	stream *recordStream
+	err    error
+}
+
+type ProduceSubmitMsg Record
+type ProduceCancel struct{}
+type BulkProgressMsg struct {
+	run      *bulkRun
+	progress BulkProgress
+}
+type BulkProduceCancel struct{}
+type ExportProgressMsg struct {
+	run      *exportRun
+	progress ExportProgress
+}
+type ExportCancel struct{}
+type SearchTickMsg struct {
+	stream *recordStream
+}
+type TailMsg struct {
+	stream   *tailStream
+	snapshot tailSnapshot
+}
+type TopicSettingsMsg struct {
+	topic    string
+	settings TopicSettings
+}
+type DeliveredMsg struct {
+	record Record
+	err    error
+}
+
 type ErrorMsg error
 type ResetMsg struct{}
 type InfoSelectedMsg struct{}
+
+type SchemasSelectedMsg struct{}
+type SubjectsLoadedMsg []Subject
+type SubjectSelectedMsg Subject
+type SubjectVersionsLoadedMsg []int
+type SchemaVersionsMsg struct {
+	subject  string
+	versions []int
+	err      error
+}
+type SchemaVersionMsg struct {
+	subject string
+	version SubjectVersion
+	err     error
+}
+type CompatibilityCheckedMsg struct {
+	candidate schemaCandidate
+	messages  []string
+	err       error
+}
+type SchemaRegisteredMsg struct {
+	candidate schemaCandidate
+	id        int
+	err       error
+}
+type SchemaClose struct{}
 type TickMsg struct{}
 
 type AddTopicSubmitMsg struct {
@@ -29,3 +99,22 @@ type ResetOffsetMsg struct {
 	topicName     string
 	offset        int64
 }
+
+type ConnectionSubmitMsg struct {
+	conn      Connection
+	skipCheck bool
+}
+type ConnectionPromptCancel struct{}
+type ConnectionCheckedMsg struct {
+	conn Connection
+	err  error
+}
+
+type DeleteConnectionMsg Connection
+type ConfirmCancelMsg struct{}
+
+type HealthTickMsg struct{}
+type HealthCheckedMsg struct {
+	name   string
+	health ClusterHealth
+}
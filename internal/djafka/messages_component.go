@@ -0,0 +1,1120 @@
+package djafka
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+	timestampLayout = "2006-01-02 15:04:05"
+	// recordBatchSize is the maximum number of records shown per update
+	recordBatchSize = 100
+	// searchTickInterval is how often the number of scanned records is
+	// updated while searching
+	searchTickInterval = 250 * time.Millisecond
+)
+
+// recordStream runs FetchMessages in the background. Its records are read by
+// the commands returned from next.
+type recordStream struct {
+	// records is unbuffered, so every record was received once done is sent
+	records chan Record
+	done    chan error
+	cancel  context.CancelFunc
+}
+
+func startRecordStream(ctx context.Context, backend KafkaBackend, topic string, start StartPosition) *recordStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &recordStream{
+		records: make(chan Record),
+		done:    make(chan error, 1),
+		cancel:  cancel,
+	}
+	go func() {
+		s.done <- backend.FetchMessages(ctx, topic, start, s.records)
+	}()
+
+	return s
+}
+
+// startSearchStream runs Search in the background, its matches are read like
+// the records of a recordStream.
+func startSearchStream(ctx context.Context, backend KafkaBackend, topic string, search *recordSearch) *recordStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &recordStream{
+		records: make(chan Record),
+		done:    make(chan error, 1),
+		cancel:  cancel,
+	}
+	go func() {
+		s.done <- Search(ctx, backend, topic, search.r, search.filter, s.records, &search.scanned)
+	}()
+
+	return s
+}
+
+// next waits for the next records and returns them together with those
+// arriving meanwhile, or returns when the stream ended.
+func (s *recordStream) next() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case record := <-s.records:
+			batch := []Record{record}
+			for len(batch) < recordBatchSize {
+				select {
+				case record := <-s.records:
+					batch = append(batch, record)
+				default:
+					return RecordsMsg{s, batch}
+				}
+			}
+			return RecordsMsg{s, batch}
+		case err := <-s.done:
+			return RecordsDoneMsg{s, err}
+		}
+	}
+}
+
+// seekKeys are the keys which change the start position, the modes which
+// need an input open the seek prompt.
+var seekKeys = map[string]SeekMode{
+	"b": SeekEarliest,
+	"e": SeekLatest,
+	"o": SeekOffset,
+	"n": SeekLastN,
+	"t": SeekTimestamp,
+}
+
+// recordSearch is a search shown instead of the records of the topic.
+type recordSearch struct {
+	filter    Filter
+	r         ExportRange
+	scanned   atomic.Int64
+	cancelled bool
+}
+
+const (
+	searchFilterInput = iota
+	searchStartInput
+	searchEndInput
+)
+
+var searchInputLabels = [...]string{"Search", "From", "To"}
+
+var searchPlaceholders = []string{
+	"text, /regexp/, key:text, header:name=text, $.path == \"value\", joined by &&",
+	"offset like 42 or 0:42,1:17, or a time (default: beginning)",
+	"offset or time, not included (default: current end)",
+}
+
+var seekPlaceholders = map[SeekMode]string{
+	SeekOffset:    "offset like 42, or per partition like 0:42,1:17",
+	SeekLastN:     "number of records per partition",
+	SeekTimestamp: "time like 2023-06-01 12:30:00, or a duration before now like 15m",
+}
+
+// shownRecord is a record with its key and value deserialized.
+type shownRecord struct {
+	Record
+	key   Decoded
+	value Decoded
+}
+
+// MessagesComponent lists the records of a topic as they arrive and shows a
+// single record in a full-screen viewport.
+type MessagesComponent struct {
+	table.Model
+	viewport viewport.Model
+	topic    string
+	columns  []table.Column
+	records  []shownRecord
+	// tableRows are the rows of records, kept in step with them so new
+	// records only add their own rows
+	tableRows []table.Row
+	// browseSize is the number of the last records kept while browsing,
+	// dropped counts the older ones dropped
+	browseSize int
+	dropped    int
+	stream     *recordStream
+	err        error
+	showRecord bool
+	width      int
+	height     int
+
+	// ctx and backend are kept to restart the stream at another position
+	ctx      context.Context
+	backend  KafkaBackend
+	position StartPosition
+	// seekInput is shown while entering the start position for seekMode
+	seekInput textinput.Model
+	seeking   bool
+	seekMode  SeekMode
+	seekErr   error
+	// searchInputs are shown while entering a search, search replaces the
+	// records by its matches
+	searchInputs [len(searchInputLabels)]textinput.Model
+	searchFocus  int
+	searchForm   bool
+	searchErr    error
+	search       *recordSearch
+	// filterInput is shown while entering filter, which drops the records of
+	// the stream not matching it. received counts the records before
+	// filtering.
+	filterInput textinput.Model
+	filtering   bool
+	filterErr   error
+	filter      *Filter
+	received    int
+	// columnsInput is shown while entering the headers shown as columns
+	columnsInput   textinput.Model
+	editingColumns bool
+	// tail follows the end of the topic instead of stream, keeping the last
+	// tailSize records. While paused the records shown are kept, tailSeen
+	// counts the records of the tail shown. last is the snapshot of the
+	// previous tick, the rates are computed from with the time of now.
+	tail     *tailStream
+	tailSize int
+	paused   bool
+	tailSeen int64
+	last     tailSnapshot
+	rate     float64
+	byteRate float64
+	now      func() time.Time
+	// settings name the formats of keys and values, with schemas relative
+	// to dir or loaded from registry if the connection has one
+	settings          TopicSettings
+	dir               string
+	registry          *SchemaRegistry
+	keyDeserializer   Deserializer
+	valueDeserializer Deserializer
+	settingsErr       error
+}
+
+// NewMessagesComponent shows the records of topic with the deserializers of
+// its settings in conn, resolving schema files relative to the config file of
+// conn. registry is the schema registry of the connection, or nil.
+func NewMessagesComponent(topic string, size tea.WindowSizeMsg, conn Connection, registry *SchemaRegistry) MessagesComponent {
+	settings, dir := conn.Topics[topic], filepath.Dir(conn.Source.Path)
+	c := MessagesComponent{
+		Model:        buildTable(nil, []table.Row{}),
+		viewport:     viewport.New(0, 0),
+		topic:        topic,
+		seekInput:    newTextInput(),
+		filterInput:  newTextInput(),
+		columnsInput: newTextInput(),
+		settings:     settings,
+		dir:          dir,
+		registry:     registry,
+		tailSize:     conn.TailBufferSize(),
+		now:          time.Now,
+		browseSize:   conn.BrowseBufferSize(),
+	}
+	c.keyDeserializer, c.settingsErr = NewDeserializer(settings.Key, dir, registry)
+	if c.settingsErr != nil {
+		c.keyDeserializer = stringDeserializer{}
+	}
+	var err error
+	if c.valueDeserializer, err = NewDeserializer(settings.Value, dir, registry); err != nil {
+		c.valueDeserializer = stringDeserializer{}
+		c.settingsErr = err
+	}
+	for _, input := range []*textinput.Model{&c.seekInput, &c.filterInput, &c.columnsInput} {
+		input.CursorStyle = cursorStyle
+		input.PromptStyle = focusedStyle
+		input.TextStyle = focusedStyle
+	}
+	c.filterInput.CharLimit = 1024
+	c.filterInput.Placeholder = "header:trace-id=abc, header:source, or any search query, empty to show all"
+	c.columnsInput.Placeholder = "header names shown as columns like trace-id,source, empty for none"
+	for i := range c.searchInputs {
+		input := newTextInput()
+		input.CursorStyle = cursorStyle
+		input.CharLimit = 1024
+		input.Placeholder = searchPlaceholders[i]
+		c.searchInputs[i] = input
+	}
+	c.Focus()
+	focusTable(&c.Model)
+	c.SetSize(size)
+
+	return c
+}
+
+// Start streams the records of the topic from backend beginning at position,
+// until Stop is called or ctx is done.
+func (c *MessagesComponent) Start(ctx context.Context, backend KafkaBackend, position StartPosition) tea.Cmd {
+	c.ctx = ctx
+	c.backend = backend
+
+	return c.seek(position)
+}
+
+// seek restarts the stream at position, dropping the records read so far.
+func (c *MessagesComponent) seek(position StartPosition) tea.Cmd {
+	c.Stop()
+	c.position = position
+	c.search = nil
+	c.records = nil
+	c.received = 0
+	c.dropped = 0
+	c.err = nil
+	c.refreshRows()
+
+	c.stream = startRecordStream(c.ctx, c.backend, c.topic, position)
+	return c.next()
+}
+
+// next reads the next records of the stream. The schemas of records in the
+// wire format of the schema registry are loaded before they are shown.
+func (c *MessagesComponent) next() tea.Cmd {
+	next, registry, ctx := c.stream.next(), c.registry, c.ctx
+	if registry == nil {
+		return next
+	}
+	return func() tea.Msg {
+		msg := next()
+		if records, ok := msg.(RecordsMsg); ok {
+			registry.Prefetch(ctx, records.records)
+		}
+		return msg
+	}
+}
+
+// startTail replaces the records by the last ones arriving at the end of the
+// topic from now on.
+func (c *MessagesComponent) startTail() tea.Cmd {
+	c.Stop()
+	c.search = nil
+	c.records = nil
+	c.dropped = 0
+	c.err = nil
+	c.paused = false
+	c.tailSeen = 0
+	c.rate, c.byteRate = 0, 0
+	c.refreshRows()
+
+	c.tail = startTailStream(c.ctx, c.backend, c.topic, c.tailSize, c.filter, c.now)
+	c.last = tailSnapshot{at: c.tail.started}
+	return c.tail.tick(c.ctx, c.registry)
+}
+
+// showTail shows the records of snapshot, deserializing only those not shown
+// before, and follows the newest one.
+func (c *MessagesComponent) showTail(snapshot tailSnapshot) {
+	fresh := int(snapshot.kept - c.tailSeen)
+	if fresh > len(snapshot.records) {
+		fresh = len(snapshot.records)
+	}
+	kept := len(snapshot.records) - fresh
+	if kept > len(c.records) {
+		kept, fresh = 0, len(snapshot.records)
+	}
+
+	records := make([]shownRecord, 0, len(snapshot.records))
+	records = append(records, c.records[len(c.records)-kept:]...)
+	rows := make([]table.Row, 0, len(snapshot.records))
+	rows = append(rows, c.tableRows[len(c.tableRows)-kept:]...)
+	for _, record := range snapshot.records[len(snapshot.records)-fresh:] {
+		shown := c.show(record)
+		records = append(records, shown)
+		rows = append(rows, c.row(shown))
+	}
+	c.records, c.tableRows = records, rows
+	c.tailSeen = snapshot.kept
+	c.SetRows(c.tableRows)
+	if len(c.records) > 0 {
+		c.SetCursor(len(c.records) - 1)
+	}
+}
+
+// startSearch replaces the records by the matches of search.
+func (c *MessagesComponent) startSearch(search *recordSearch) tea.Cmd {
+	c.Stop()
+	c.search = search
+	c.records = nil
+	c.dropped = 0
+	c.err = nil
+	c.refreshRows()
+
+	c.stream = startSearchStream(c.ctx, c.backend, c.topic, search)
+	return tea.Batch(c.next(), searchTick(c.stream))
+}
+
+func searchTick(stream *recordStream) tea.Cmd {
+	return tea.Tick(searchTickInterval, func(time.Time) tea.Msg {
+		return SearchTickMsg{stream}
+	})
+}
+
+// Stop ends streaming records.
+func (c *MessagesComponent) Stop() {
+	if c.stream != nil {
+		c.stream.cancel()
+	}
+	if c.tail != nil {
+		c.tail.cancel()
+		c.tail = nil
+	}
+}
+
+// CanClose reports whether the list of records is shown, neither a single
+// record, a prompt nor a search.
+func (c MessagesComponent) CanClose() bool {
+	return !c.showRecord && !c.seeking && !c.searchForm && !c.filtering && !c.editingColumns && c.search == nil
+}
+
+func (c *MessagesComponent) SetSize(size tea.WindowSizeMsg) {
+	c.width = size.Width
+	c.height = size.Height
+
+	fixed := []table.Column{
+		{Title: "Partition", Width: 9},
+		{Title: "Offset", Width: 10},
+		{Title: "Timestamp", Width: len(timestampLayout)},
+		{Title: "Key", Width: 16},
+	}
+	// configured header columns replace the column of all headers
+	if len(c.settings.HeaderColumns) == 0 {
+		fixed = append(fixed, table.Column{Title: "Headers", Width: 20})
+	}
+	if c.registry != nil {
+		fixed = append(fixed, table.Column{Title: "Schema", Width: 16})
+	}
+	for _, name := range c.settings.HeaderColumns {
+		fixed = append(fixed, table.Column{Title: name, Width: 16})
+	}
+	// every column is padded by one space on both sides, the table by a
+	// border
+	valueWidth := c.width - 2 - 2*(len(fixed)+1)
+	for _, column := range fixed {
+		valueWidth -= column.Width
+	}
+	c.columns = append(fixed, table.Column{Title: "Value", Width: atLeast(valueWidth, 10)})
+	c.SetColumns(c.columns)
+	c.refreshRows()
+	c.Model.SetHeight(atLeast(c.height-7, 1))
+
+	c.viewport.Width = c.width
+	c.viewport.Height = atLeast(c.height-4, 1)
+}
+
+// refreshRows renders the rows of all records again, e.g. after the columns
+// changed.
+func (c *MessagesComponent) refreshRows() {
+	c.tableRows = c.rows()
+	c.SetRows(c.tableRows)
+}
+
+// appendRecords adds records to the end of the list. While browsing only the
+// last browseSize records are kept, the cursor stays on its record.
+func (c *MessagesComponent) appendRecords(records []shownRecord) {
+	for _, record := range records {
+		c.records = append(c.records, record)
+		c.tableRows = append(c.tableRows, c.row(record))
+	}
+
+	drop := len(c.records) - c.browseSize
+	if drop <= 0 {
+		c.SetRows(c.tableRows)
+		return
+	}
+	// the dropped records are freed once append moves the rest
+	cursor := c.Cursor()
+	c.records, c.tableRows = c.records[drop:], c.tableRows[drop:]
+	c.dropped += drop
+	c.SetRows(c.tableRows)
+	c.SetCursor(atLeast(cursor-drop, 0))
+}
+
+// insertRecords adds the matches of a search ordered by timestamp, partition
+// and offset, as the matches of all partitions arrive in any order. The cursor
+// stays on its record, or on the first row until it is moved.
+func (c *MessagesComponent) insertRecords(records []shownRecord) {
+	cursor := c.Cursor()
+	for _, record := range records {
+		i := sort.Search(len(c.records), func(i int) bool {
+			return recordBefore(record, c.records[i])
+		})
+		if i <= cursor && 0 < cursor && cursor < len(c.records) {
+			cursor++
+		}
+
+		c.records = append(c.records, shownRecord{})
+		copy(c.records[i+1:], c.records[i:])
+		c.records[i] = record
+		c.tableRows = append(c.tableRows, nil)
+		copy(c.tableRows[i+1:], c.tableRows[i:])
+		c.tableRows[i] = c.row(record)
+	}
+
+	c.SetRows(c.tableRows)
+	c.SetCursor(cursor)
+}
+
+func recordBefore(a, b shownRecord) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	if a.Partition != b.Partition {
+		return a.Partition < b.Partition
+	}
+	return a.Offset < b.Offset
+}
+
+func (c MessagesComponent) rows() []table.Row {
+	rows := make([]table.Row, 0, len(c.records))
+	for _, record := range c.records {
+		rows = append(rows, c.row(record))
+	}
+
+	return rows
+}
+
+func (c MessagesComponent) row(record shownRecord) table.Row {
+	row := table.Row{
+		strconv.Itoa(int(record.Partition)),
+		strconv.FormatInt(record.Offset, 10),
+		record.Timestamp.Format(timestampLayout),
+		previewDecoded(record.key, c.columns[3].Width),
+	}
+	if len(c.settings.HeaderColumns) == 0 {
+		row = append(row, preview([]byte(formatHeaders(record.Record)), c.columns[len(row)].Width))
+	}
+	if c.registry != nil {
+		row = append(row, preview([]byte(record.schema()), c.columns[len(row)].Width))
+	}
+	for _, name := range c.settings.HeaderColumns {
+		row = append(row, preview([]byte(headerValue(record.Record, name)), c.columns[len(row)].Width))
+	}
+	return append(row, previewDecoded(record.value, c.columns[len(row)].Width))
+}
+
+// schema names the registry schema of the value, or of the key if the value
+// has none.
+func (r shownRecord) schema() string {
+	if r.value.Schema != "" {
+		return r.value.Schema
+	}
+	return r.key.Schema
+}
+
+func (c MessagesComponent) show(record Record) shownRecord {
+	return shownRecord{
+		Record: record,
+		key:    Decode(c.keyDeserializer, record.Key),
+		value:  Decode(c.valueDeserializer, record.Value),
+	}
+}
+
+// cycleFormat switches the key or value to the next format which can be
+// used with the current settings and returns a command to remember it.
+func (c *MessagesComponent) cycleFormat(key bool) tea.Cmd {
+	serde := c.settings.Value
+	if key {
+		serde = c.settings.Key
+	}
+	next := Serde{Format: FormatString}
+	if serde != nil {
+		next = *serde
+	}
+
+	current := 0
+	for i, format := range DeserializerFormats {
+		if format == next.Format {
+			current = i
+		}
+	}
+	var deserializer Deserializer
+	for i := 1; i < len(DeserializerFormats); i++ {
+		next.Format = DeserializerFormats[(current+i)%len(DeserializerFormats)]
+		var err error
+		if deserializer, err = NewDeserializer(&next, c.dir, c.registry); err == nil {
+			break
+		}
+	}
+	if deserializer == nil {
+		return nil
+	}
+
+	if key {
+		c.settings.Key = &next
+		c.keyDeserializer = deserializer
+	} else {
+		c.settings.Value = &next
+		c.valueDeserializer = deserializer
+	}
+	c.settingsErr = nil
+	for i := range c.records {
+		c.records[i] = c.show(c.records[i].Record)
+	}
+	c.refreshRows()
+
+	topic, settings := c.topic, c.settings
+	return func() tea.Msg { return TopicSettingsMsg{topic, settings} }
+}
+
+// SetSettingsError shows why the settings of the topic could not be saved.
+func (c *MessagesComponent) SetSettingsError(err error) {
+	c.settingsErr = err
+}
+
+func (c MessagesComponent) Update(msg tea.Msg) (MessagesComponent, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.SetSize(msg)
+		return c, nil
+	case RecordsMsg:
+		if msg.stream != c.stream {
+			return c, nil
+		}
+		first := len(c.records) == 0
+		shown := make([]shownRecord, 0, len(msg.records))
+		for _, record := range msg.records {
+			c.received++
+			if c.search == nil && c.filter != nil && !c.filter.Match(record) {
+				continue
+			}
+			shown = append(shown, c.show(record))
+		}
+		if c.search != nil {
+			c.insertRecords(shown)
+		} else {
+			c.appendRecords(shown)
+		}
+		if first {
+			c.SetCursor(0)
+		}
+		return c, c.next()
+	case RecordsDoneMsg:
+		if msg.stream != c.stream {
+			return c, nil
+		}
+		c.err = msg.err
+		c.stream = nil
+		return c, nil
+	case SearchTickMsg:
+		if msg.stream != c.stream {
+			return c, nil
+		}
+		return c, searchTick(c.stream)
+	case TailMsg:
+		if msg.stream != c.tail {
+			return c, nil
+		}
+		snapshot := msg.snapshot
+		if elapsed := snapshot.at.Sub(c.last.at).Seconds(); elapsed > 0 {
+			c.rate = float64(snapshot.received-c.last.received) / elapsed
+			c.byteRate = float64(snapshot.bytes-c.last.bytes) / elapsed
+		}
+		c.last = snapshot
+		// the record view keeps showing the record at the cursor
+		if !c.paused && !c.showRecord {
+			c.showTail(snapshot)
+		}
+		if snapshot.stopped {
+			c.err = snapshot.err
+			return c, nil
+		}
+		return c, c.tail.tick(c.ctx, c.registry)
+	case tea.KeyMsg:
+		if c.seeking {
+			return c.updateSeekInput(msg)
+		}
+		if c.filtering {
+			return c.updateFilterInput(msg)
+		}
+		if c.editingColumns {
+			return c.updateColumnsInput(msg)
+		}
+		if c.searchForm {
+			return c.updateSearchForm(msg)
+		}
+		if c.showRecord {
+			if msg.String() == ESC {
+				c.showRecord = false
+				return c, nil
+			}
+			var cmd tea.Cmd
+			c.viewport, cmd = c.viewport.Update(msg)
+			return c, cmd
+		}
+		if msg.String() == "/" {
+			c.searchForm = true
+			c.searchErr = nil
+			c.searchFocus = searchFilterInput
+			return c, c.focusSearch()
+		}
+		if c.search != nil {
+			switch msg.String() {
+			case "x":
+				if c.stream != nil {
+					c.search.cancelled = true
+					c.Stop()
+				}
+				return c, nil
+			case ESC:
+				return c, c.seek(c.position)
+			}
+		}
+		switch msg.String() {
+		case "F":
+			return c, c.startTail()
+		case " ":
+			if c.tail != nil {
+				c.paused = !c.paused
+				if !c.paused {
+					c.showTail(c.last)
+				}
+			}
+			return c, nil
+		case "v":
+			return c, c.cycleFormat(false)
+		case "K":
+			return c, c.cycleFormat(true)
+		case "f":
+			if c.search != nil {
+				return c, nil
+			}
+			c.filtering = true
+			c.filterErr = nil
+			c.filterInput.SetValue("")
+			if c.filter != nil {
+				c.filterInput.SetValue(c.filter.String())
+			}
+			c.filterInput.CursorEnd()
+			return c, c.filterInput.Focus()
+		case "ctrl+s":
+			// saves the settings again, e.g. after the config file could
+			// not be saved
+			topic, settings := c.topic, c.settings
+			return c, func() tea.Msg { return TopicSettingsMsg{topic, settings} }
+		case "H":
+			c.editingColumns = true
+			c.columnsInput.SetValue(strings.Join(c.settings.HeaderColumns, ","))
+			c.columnsInput.CursorEnd()
+			return c, c.columnsInput.Focus()
+		}
+		if msg.String() == "enter" && len(c.records) > 0 {
+			c.showRecord = true
+			c.viewport.SetContent(formatRecord(c.records[c.Cursor()], c.width))
+			c.viewport.GotoTop()
+			return c, nil
+		}
+		if mode, ok := seekKeys[msg.String()]; ok {
+			if _, needsInput := seekPlaceholders[mode]; !needsInput {
+				return c, c.seek(StartPosition{Mode: mode})
+			}
+			c.seeking = true
+			c.seekMode = mode
+			c.seekInput.Placeholder = seekPlaceholders[mode]
+			c.seekInput.SetValue("")
+			c.seekErr = nil
+			return c, c.seekInput.Focus()
+		}
+	}
+
+	var cmd tea.Cmd
+	c.Model, cmd = c.Model.Update(msg)
+	return c, cmd
+}
+
+func (c MessagesComponent) updateSeekInput(msg tea.KeyMsg) (MessagesComponent, tea.Cmd) {
+	switch msg.String() {
+	case ESC:
+		c.seeking = false
+		c.seekInput.Blur()
+		return c, nil
+	case "enter":
+		position, err := ParseStartPosition(c.seekMode, c.seekInput.Value())
+		if err != nil {
+			c.seekErr = err
+			return c, nil
+		}
+		c.seeking = false
+		c.seekInput.Blur()
+		return c, c.seek(position)
+	}
+
+	var cmd tea.Cmd
+	c.seekInput, cmd = c.seekInput.Update(msg)
+	return c, cmd
+}
+
+func (c MessagesComponent) updateFilterInput(msg tea.KeyMsg) (MessagesComponent, tea.Cmd) {
+	switch msg.String() {
+	case ESC:
+		c.filtering = false
+		c.filterInput.Blur()
+		return c, nil
+	case "enter":
+		c.filter = nil
+		if query := strings.TrimSpace(c.filterInput.Value()); query != "" {
+			filter, err := ParseFilter(query)
+			if err != nil {
+				c.filterErr = err
+				return c, nil
+			}
+			c.filter = &filter
+		}
+		c.filtering = false
+		c.filterInput.Blur()
+		// restarts the stream, so the filter applies to all records
+		if c.tail != nil {
+			return c, c.startTail()
+		}
+		return c, c.seek(c.position)
+	}
+
+	var cmd tea.Cmd
+	c.filterInput, cmd = c.filterInput.Update(msg)
+	return c, cmd
+}
+
+func (c MessagesComponent) updateColumnsInput(msg tea.KeyMsg) (MessagesComponent, tea.Cmd) {
+	switch msg.String() {
+	case ESC:
+		c.editingColumns = false
+		c.columnsInput.Blur()
+		return c, nil
+	case "enter":
+		var names []string
+		for _, name := range strings.Split(c.columnsInput.Value(), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		c.editingColumns = false
+		c.columnsInput.Blur()
+		c.settings.HeaderColumns = names
+		c.SetSize(tea.WindowSizeMsg{Width: c.width, Height: c.height})
+
+		topic, settings := c.topic, c.settings
+		return c, func() tea.Msg { return TopicSettingsMsg{topic, settings} }
+	}
+
+	var cmd tea.Cmd
+	c.columnsInput, cmd = c.columnsInput.Update(msg)
+	return c, cmd
+}
+
+func (c MessagesComponent) updateSearchForm(msg tea.KeyMsg) (MessagesComponent, tea.Cmd) {
+	switch msg.String() {
+	case ESC:
+		c.searchForm = false
+		c.searchFocus = -1
+		return c, c.focusSearch()
+	case "tab", "shift+tab", "up", "down":
+		if msg.String() == "up" || msg.String() == "shift+tab" {
+			c.searchFocus = (c.searchFocus + len(c.searchInputs) - 1) % len(c.searchInputs)
+		} else {
+			c.searchFocus = (c.searchFocus + 1) % len(c.searchInputs)
+		}
+		return c, c.focusSearch()
+	case "enter":
+		filter, err := ParseFilter(c.searchInputs[searchFilterInput].Value())
+		if err != nil {
+			c.searchErr = err
+			return c, nil
+		}
+		r, err := ParseExportRange("", c.searchInputs[searchStartInput].Value(), c.searchInputs[searchEndInput].Value())
+		if err != nil {
+			c.searchErr = err
+			return c, nil
+		}
+		c.searchForm = false
+		c.searchFocus = -1
+		c.focusSearch()
+		return c, c.startSearch(&recordSearch{filter: filter, r: r})
+	}
+
+	cmds := make([]tea.Cmd, len(c.searchInputs))
+	for i := range c.searchInputs {
+		c.searchInputs[i], cmds[i] = c.searchInputs[i].Update(msg)
+	}
+	return c, tea.Batch(cmds...)
+}
+
+// focusSearch focuses the search input at searchFocus, none if it is -1.
+func (c *MessagesComponent) focusSearch() tea.Cmd {
+	var cmd tea.Cmd
+	for i := range c.searchInputs {
+		if i == c.searchFocus {
+			cmd = c.searchInputs[i].Focus()
+			c.searchInputs[i].PromptStyle = focusedStyle
+			c.searchInputs[i].TextStyle = focusedStyle
+			continue
+		}
+		c.searchInputs[i].Blur()
+		c.searchInputs[i].PromptStyle = noStyle
+		c.searchInputs[i].TextStyle = noStyle
+	}
+
+	return cmd
+}
+
+func (c MessagesComponent) status() string {
+	if c.seeking && c.seekErr != nil {
+		return fmt.Sprintf("Invalid start position: %s", c.seekErr)
+	}
+	if c.searchForm && c.searchErr != nil {
+		return fmt.Sprintf("Invalid search: %s", c.searchErr)
+	}
+	if c.filtering && c.filterErr != nil {
+		return fmt.Sprintf("Invalid filter: %s", c.filterErr)
+	}
+	if c.settingsErr != nil {
+		return c.settingsErr.Error()
+	}
+
+	if c.search != nil {
+		state := "searching"
+		if c.err != nil {
+			state = fmt.Sprintf("failed: %s", c.err)
+		} else if c.search.cancelled {
+			state = "cancelled"
+		} else if c.stream == nil {
+			state = "done"
+		}
+		return fmt.Sprintf("%d matches in %d records · %s · %s · %s",
+			len(c.records), c.search.scanned.Load(), c.search.r, c.search.filter, state)
+	}
+
+	if c.tail != nil {
+		state := "following"
+		if c.last.stopped {
+			state = "stopped"
+			if c.err != nil {
+				state = fmt.Sprintf("stopped: %s", c.err)
+			}
+		} else if c.paused {
+			state = fmt.Sprintf("paused · %d new", c.last.kept-c.tailSeen)
+		}
+		if c.filter != nil {
+			return fmt.Sprintf("%d of the last %d records · %s · %s", len(c.records), c.tailSize, c.filter, state)
+		}
+		return fmt.Sprintf("%d of the last %d records · %s", len(c.records), c.tailSize, state)
+	}
+
+	state := "streaming"
+	if c.err != nil {
+		state = fmt.Sprintf("stopped: %s", c.err)
+	} else if c.stream == nil {
+		state = "stopped"
+	}
+
+	if c.filter != nil {
+		return fmt.Sprintf("%d of %d records%s %s · %s · %s", len(c.records)+c.dropped, c.received, c.droppedNote(), c.position, c.filter, state)
+	}
+	return fmt.Sprintf("%d records%s %s · %s", len(c.records)+c.dropped, c.droppedNote(), c.position, state)
+}
+
+// droppedNote tells how many records are shown if older ones were dropped.
+func (c MessagesComponent) droppedNote() string {
+	if c.dropped == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (last %d shown)", len(c.records))
+}
+
+func (c MessagesComponent) View() string {
+	if c.showRecord {
+		record := c.records[c.Cursor()]
+		title := titleStyle.Render(fmt.Sprintf("%s[%d]@%d", record.Topic, record.Partition, record.Offset))
+		help := helpStyle.Render(fmt.Sprintf("↑/↓: scroll • esc: back to messages • %3.f%%", c.viewport.ScrollPercent()*100))
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", c.viewport.View(), help)
+	}
+
+	formats := fmt.Sprintf("key: %s, value: %s", c.keyDeserializer.Format(), c.valueDeserializer.Format())
+	title := titleStyle.Render(fmt.Sprintf("Messages of %s (%s)", c.topic, formats))
+	status := helpStyle.Render(c.status())
+	help := helpStyle.Render("enter: open • b/e/o/n/t: seek • F: tail • /: search • f: filter • H: header columns • v/K: formats • esc: back")
+	if c.tail != nil {
+		title = titleStyle.Render(fmt.Sprintf("Tail of %s (%s) · %.1f msg/s · %s/s", c.topic, formats, c.rate, formatBytes(c.byteRate)))
+		help = helpStyle.Render("enter: open • space: pause/resume • b/e/o/n/t: seek • f: filter • H: header columns • v/K: formats • esc: back")
+	}
+	if c.search != nil {
+		title = titleStyle.Render(fmt.Sprintf("Search in %s (%s)", c.topic, formats))
+		help = helpStyle.Render("enter: open record • /: new search • x: cancel search • v/K: value/key format • esc: back to messages")
+	}
+	if c.seeking {
+		help = c.seekInput.View()
+	} else if c.filtering {
+		help = c.filterInput.View()
+	} else if c.editingColumns {
+		help = c.columnsInput.View()
+	} else if c.searchForm {
+		lines := []string{}
+		for i, input := range c.searchInputs {
+			lines = append(lines, inputStyle.Copy().Width(8).Render(searchInputLabels[i])+input.View())
+		}
+		lines = append(lines, helpStyle.Render("enter: search • tab: next field • esc: cancel"))
+		help = lipgloss.JoinVertical(lipgloss.Left, lines...)
+		// the form takes the place of the help and some rows of the table
+		c.Model.SetHeight(atLeast(c.height-7-len(c.searchInputs), 1))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, status, focusTable(&c.Model).Render(c.Model.View()), help)
+}
+
+// formatRecord renders every field of the record for the record view.
+func formatRecord(record shownRecord, width int) string {
+	label := inputStyle.Copy().Width(12)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s\n", label.Render("Topic"), record.Topic)
+	fmt.Fprintf(&b, "%s%d\n", label.Render("Partition"), record.Partition)
+	fmt.Fprintf(&b, "%s%d\n", label.Render("Offset"), record.Offset)
+	fmt.Fprintf(&b, "%s%s\n", label.Render("Timestamp"), record.Timestamp.Format(timestampLayout))
+	fmt.Fprintf(&b, "%s%s\n", label.Render("Key"), formatDecoded(record.key, width-12))
+	if record.key.Schema != "" || record.value.Schema != "" {
+		fmt.Fprintf(&b, "%s%s\n", label.Render("Schema"), formatSchemas(record))
+	}
+	fmt.Fprintf(&b, "%s\n", label.Render("Headers"))
+	fmt.Fprintf(&b, "%s\n", formatHeaderTable(record.Headers, width))
+	fmt.Fprintf(&b, "\n%s\n", label.Render("Value"))
+	b.WriteString(formatDecoded(record.value, width))
+
+	return b.String()
+}
+
+// formatSchemas names the registry schemas of the key and value.
+func formatSchemas(record shownRecord) string {
+	schemas := []string{}
+	if record.key.Schema != "" {
+		schemas = append(schemas, "key "+record.key.Schema)
+	}
+	if record.value.Schema != "" {
+		schemas = append(schemas, "value "+record.value.Schema)
+	}
+	return strings.Join(schemas, ", ")
+}
+
+// formatDecoded renders a key or value for the record view, highlighting
+// JSON and showing why deserializing failed above the raw data.
+func formatDecoded(decoded Decoded, width int) string {
+	text := lipgloss.NewStyle().Width(width).Render(decoded.Text)
+	if decoded.JSON {
+		text = highlightJSON(decoded.Text)
+	}
+	if decoded.Err != nil {
+		return statusStyle.Render(decoded.Err.Error()) + "\n" + text
+	}
+	return text
+}
+
+// previewDecoded returns the preview of a key or value, marked if it could
+// not be deserialized.
+func previewDecoded(decoded Decoded, width int) string {
+	if decoded.Err != nil {
+		return preview([]byte("✗ "+decoded.Line), width)
+	}
+	return preview([]byte(decoded.Line), width)
+}
+
+// formatHeaderTable renders headers as a table of their keys and values,
+// which are cut off to fit into width.
+func formatHeaderTable(headers []kafka.Header, width int) string {
+	if len(headers) == 0 {
+		return helpStyle.Render("none")
+	}
+
+	keyWidth := len("Key")
+	for _, header := range headers {
+		keyWidth = atLeast(len([]rune(header.Key)), keyWidth)
+	}
+	if keyWidth > width/3 {
+		keyWidth = atLeast(width/3, len("Key"))
+	}
+	// the border and the padding of both columns take 6 characters
+	valueWidth := atLeast(width-keyWidth-6, 10)
+
+	rows := make([]table.Row, len(headers))
+	for i, header := range headers {
+		rows[i] = table.Row{preview([]byte(header.Key), keyWidth), preview(header.Value, valueWidth)}
+	}
+	t := table.New(
+		table.WithColumns([]table.Column{{Title: "Key", Width: keyWidth}, {Title: "Value", Width: valueWidth}}),
+		table.WithRows(rows),
+		table.WithHeight(len(rows)),
+	)
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(false)
+	s.Selected = lipgloss.NewStyle()
+	t.SetStyles(s)
+
+	return baseStyle.Render(t.View())
+}
+
+// headerValue returns the values of the headers called name, joined by
+// commas if there are several.
+func headerValue(record Record, name string) string {
+	values := []string{}
+	for _, header := range record.Headers {
+		if header.Key == name {
+			values = append(values, string(header.Value))
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+func formatHeaders(record Record) string {
+	headers := make([]string, 0, len(record.Headers))
+	for _, header := range record.Headers {
+		headers = append(headers, fmt.Sprintf("%s=%s", header.Key, header.Value))
+	}
+
+	return strings.Join(headers, ", ")
+}
+
+// preview returns value on a single line of at most width characters, with
+// control characters replaced.
+func preview(value []byte, width int) string {
+	text := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return '·'
+		}
+		return r
+	}, string(value))
+
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return text
+}
+
+// formatBytes returns a number of bytes in B, KB or MB.
+func formatBytes(bytes float64) string {
+	switch {
+	case bytes >= 1000*1000:
+		return fmt.Sprintf("%.1f MB", bytes/1000/1000)
+	case bytes >= 1000:
+		return fmt.Sprintf("%.1f KB", bytes/1000)
+	}
+	return fmt.Sprintf("%.0f B", bytes)
+}
+
+func atLeast(value int, minimum int) int {
+	if value < minimum {
+		return minimum
+	}
+	return value
+}
@@ -0,0 +1,224 @@
+package djafka
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+	produceKeyInput = iota
+	producePartitionInput
+	produceHeadersInput
+	// produceValueInput is the textarea following the inputs
+	produceValueInput
+)
+
+var produceInputLabels = [...]string{"Key", "Partition", "Headers"}
+
+var produceInputPlaceholders = []string{
+	"optional",
+	"any",
+	"key=value,other=value",
+}
+
+// ProducePrompt is the prompt to send a record to a topic. It stays open after
+// sending, so further records can be sent.
+type ProducePrompt struct {
+	focusIndex int
+	inputs     [len(produceInputLabels)]textinput.Model
+	value      textarea.Model
+	logger     *log.Logger
+	topic      Topic
+	status     string
+	sending    bool
+}
+
+func InitialProducePrompt(log *log.Logger, topic Topic, width int) ProducePrompt {
+	m := ProducePrompt{
+		logger: log,
+		topic:  topic,
+	}
+
+	var t textinput.Model
+
+	for i := range m.inputs {
+		t = newTextInput()
+		t.CursorStyle = cursorStyle
+		t.CharLimit = 1024
+		t.Placeholder = produceInputPlaceholders[i]
+
+		if i == produceKeyInput {
+			t.Focus()
+			t.PromptStyle = focusedStyle
+			t.TextStyle = focusedStyle
+		}
+
+		m.inputs[i] = t
+	}
+
+	m.value = newTextArea()
+	m.value.Placeholder = "Value"
+	m.value.CharLimit = 0
+	m.value.SetWidth(atLeast(width-16, 20))
+	m.value.SetHeight(10)
+
+	return m
+}
+
+func (m ProducePrompt) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// SetDelivery shows the delivery report of the last record.
+func (m *ProducePrompt) SetDelivery(msg DeliveredMsg) {
+	m.sending = false
+	if msg.err != nil {
+		m.status = msg.err.Error()
+		return
+	}
+
+	m.status = fmt.Sprintf("Delivered to %s[%d]@%d", msg.record.Topic, msg.record.Partition, msg.record.Offset)
+}
+
+// Record returns the record described by the current input values.
+func (m ProducePrompt) Record() (Record, error) {
+	record := Record{
+		Topic:     m.topic.Name,
+		Partition: kafka.PartitionAny,
+		Value:     []byte(m.value.Value()),
+	}
+	if key := m.inputs[produceKeyInput].Value(); key != "" {
+		record.Key = []byte(key)
+	}
+
+	if partition := strings.TrimSpace(m.inputs[producePartitionInput].Value()); partition != "" {
+		p, err := strconv.ParseInt(partition, 10, 32)
+		if err != nil || p < 0 || (m.topic.PartitionCount > 0 && int(p) >= m.topic.PartitionCount) {
+			return record, fmt.Errorf("Partition must be between 0 and %d.", m.topic.PartitionCount-1)
+		}
+		record.Partition = int32(p)
+	}
+
+	headers, err := parseHeaders(m.inputs[produceHeadersInput].Value())
+	if err != nil {
+		return record, err
+	}
+	record.Headers = headers
+
+	return record, nil
+}
+
+// parseHeaders parses headers like "key=value,other=value".
+func parseHeaders(input string) ([]kafka.Header, error) {
+	headers := []kafka.Header{}
+	for _, item := range strings.Split(input, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("Header '%s' is not like key=value.", strings.TrimSpace(item))
+		}
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	return headers, nil
+}
+
+func (m ProducePrompt) Update(msg tea.Msg) (ProducePrompt, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", ESC:
+			return m, func() tea.Msg { return ProduceCancel{} }
+
+		case "ctrl+s":
+			if m.sending {
+				return m, nil
+			}
+			record, err := m.Record()
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			m.sending = true
+			m.status = "Sending ..."
+			m.logger.Println("Producing record to", record.Topic)
+			return m, func() tea.Msg { return ProduceSubmitMsg(record) }
+
+		// Set focus to next input, the value editor uses up and down itself
+		case "tab", "shift+tab":
+			if msg.String() == "shift+tab" {
+				m.focusIndex--
+			} else {
+				m.focusIndex++
+			}
+
+			if m.focusIndex > produceValueInput {
+				m.focusIndex = 0
+			} else if m.focusIndex < 0 {
+				m.focusIndex = produceValueInput
+			}
+
+			return m, m.focus()
+		}
+	}
+
+	// Handle character input and blinking
+	cmds := make([]tea.Cmd, len(m.inputs)+1)
+	for i := range m.inputs {
+		m.inputs[i], cmds[i] = m.inputs[i].Update(msg)
+	}
+	m.value, cmds[len(m.inputs)] = m.value.Update(msg)
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m *ProducePrompt) focus() tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.inputs)+1)
+	for i := range m.inputs {
+		if i == m.focusIndex {
+			// Set focused state
+			cmds[i] = m.inputs[i].Focus()
+			m.inputs[i].PromptStyle = focusedStyle
+			m.inputs[i].TextStyle = focusedStyle
+			continue
+		}
+		// Remove focused state
+		m.inputs[i].Blur()
+		m.inputs[i].PromptStyle = noStyle
+		m.inputs[i].TextStyle = noStyle
+	}
+
+	m.value.Blur()
+	if m.focusIndex == produceValueInput {
+		cmds[len(m.inputs)] = m.value.Focus()
+	}
+
+	return tea.Batch(cmds...)
+}
+
+func (m ProducePrompt) View() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\n\t %s\n\n", titleStyle.Render(fmt.Sprintf("Produce to %s", m.topic.Name)))
+	for i, input := range m.inputs {
+		fmt.Fprintf(&b, "\t %s\n\t %s\n", inputStyle.Width(30).Render(produceInputLabels[i]), input.View())
+	}
+	fmt.Fprintf(&b, "\t %s\n", inputStyle.Width(30).Render("Value"))
+	for _, line := range strings.Split(m.value.View(), "\n") {
+		fmt.Fprintf(&b, "\t %s\n", line)
+	}
+	fmt.Fprintf(&b, "\n\t %s\n\n", statusStyle.Render(m.status))
+	fmt.Fprintf(&b, "\t %s\n", helpStyle.Render("ctrl+s: send • tab: next field • esc: close"))
+
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package djafka
+
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Record is a message read from a topic partition.
+type Record struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	Key       []byte
+	Value     []byte
+	Headers   []kafka.Header
+}
+
+func newRecord(msg *kafka.Message) Record {
+	record := Record{
+		Partition: msg.TopicPartition.Partition,
+		Offset:    int64(msg.TopicPartition.Offset),
+		Timestamp: msg.Timestamp,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   msg.Headers,
+	}
+	if msg.TopicPartition.Topic != nil {
+		record.Topic = *msg.TopicPartition.Topic
+	}
+
+	return record
+}
@@ -15,20 +15,19 @@ import (
 
 type ResetOffsetPrompt struct {
 	focusIndex int
-	inputs     []textinput.Model
+	inputs     [3]textinput.Model
 	cursorMode textinput.CursorMode
 	logger     *log.Logger
 }
 
 func InitialResetOffsetPrompt(log *log.Logger) ResetOffsetPrompt {
 	m := ResetOffsetPrompt{
-		inputs: make([]textinput.Model, 3),
 		logger: log,
 	}
 
 	var t textinput.Model
 
-	t = textinput.New()
+	t = newTextInput()
 	t.CursorStyle = cursorStyle
 	t.CharLimit = 5
 	t.Focus()
@@ -38,7 +37,7 @@ func InitialResetOffsetPrompt(log *log.Logger) ResetOffsetPrompt {
 	t.Validate = validateTopic
 	m.inputs[0] = t
 
-	t = textinput.New()
+	t = newTextInput()
 	t.CursorStyle = cursorStyle
 	t.CharLimit = 10000
 	t.Focus()
@@ -48,7 +47,7 @@ func InitialResetOffsetPrompt(log *log.Logger) ResetOffsetPrompt {
 	t.Validate = validateTopic
 	m.inputs[1] = t
 
-	t = textinput.New()
+	t = newTextInput()
 	t.CursorStyle = cursorStyle
 	t.CharLimit = 10000
 	t.Focus()
@@ -13,6 +13,12 @@ func selectConsumer(c Consumer) tea.Cmd {
 	}
 }
 
+func selectSubject(s Subject) tea.Cmd {
+	return func() tea.Msg {
+		return SubjectSelectedMsg(s)
+	}
+}
+
 func selectTopic(t Topic) tea.Cmd {
 	return func() tea.Msg {
 		return TopicSelectedMsg(t)
@@ -23,6 +29,11 @@ type ResultComponent struct {
 	table.Model
 	consumers  map[string]Consumer
 	isConsumer bool
+	subjects   map[string]Subject
+	isSubject  bool
+	// restore is the row selected after the next load, e.g. when switching
+	// back to a cluster
+	restore string
 }
 
 func (c ResultComponent) Update(msg tea.Msg) (ResultComponent, tea.Cmd) {
@@ -39,11 +50,32 @@ func (c ResultComponent) Update(msg tea.Msg) (ResultComponent, tea.Cmd) {
 		}
 
 		c.consumers = consumers
-		return c, selectConsumer(msg[0])
+		c.isSubject = false
+		if len(msg) == 0 {
+			return c, nil
+		}
+		return c, selectConsumer(msg[c.restoreCursor()])
+	case SubjectsLoadedMsg:
+		c.SetSubjects(msg)
+		c.isConsumer = false
+		c.isSubject = true
+
+		c.subjects = map[string]Subject{}
+		for _, item := range msg {
+			c.subjects[item.Name] = item
+		}
+		if len(msg) == 0 {
+			return c, nil
+		}
+		return c, selectSubject(msg[c.restoreCursor()])
 	case TopicsLoadedMsg:
 		c.SetTopics(msg)
 		c.isConsumer = false
-		return c, selectTopic(msg[0])
+		c.isSubject = false
+		if len(msg) == 0 {
+			return c, nil
+		}
+		return c, selectTopic(msg[c.restoreCursor()])
 
 	default:
 		if len(c.Rows()) > 0 {
@@ -56,6 +88,9 @@ func (c ResultComponent) Update(msg tea.Msg) (ResultComponent, tea.Cmd) {
 				if c.isConsumer {
 					return c, tea.Batch(cmd, selectConsumer(c.consumers[currentRow]))
 				}
+				if c.isSubject {
+					return c, tea.Batch(cmd, selectSubject(c.subjects[currentRow]))
+				}
 				return c, tea.Batch(cmd, selectTopic(Topic{Name: currentRow}))
 			}
 		}
@@ -82,3 +117,47 @@ func (c *ResultComponent) SetConsumers(items []Consumer) {
 	c.Model.SetRows(rows)
 	c.Model.SetCursor(0)
 }
+
+func (c *ResultComponent) SetSubjects(items []Subject) {
+	rows := []table.Row{}
+	for _, item := range items {
+		compatibility := item.Compatibility
+		if item.Global {
+			compatibility += " (global)"
+		}
+		rows = append(rows, table.Row{item.Name, compatibility})
+	}
+	c.Model.SetRows(rows)
+	c.Model.SetCursor(0)
+}
+
+// IsTopics reports whether topics are listed.
+func (c *ResultComponent) IsTopics() bool {
+	return !c.isConsumer && !c.isSubject
+}
+
+// SelectedSubject returns the selected subject, if subjects are listed.
+func (c *ResultComponent) SelectedSubject() (Subject, bool) {
+	if !c.isSubject || len(c.Rows()) == 0 {
+		return Subject{}, false
+	}
+	return c.subjects[c.SelectedRow()[0]], true
+}
+
+// RestoreOnLoad selects the row with the given key after the next load.
+func (c *ResultComponent) RestoreOnLoad(key string) {
+	c.restore = key
+}
+
+// restoreCursor moves the cursor to the row to restore, if any, and returns
+// the selected index.
+func (c *ResultComponent) restoreCursor() int {
+	for i, row := range c.Rows() {
+		if c.restore != "" && row[0] == c.restore {
+			c.SetCursor(i)
+		}
+	}
+	c.restore = ""
+
+	return c.Cursor()
+}
@@ -0,0 +1,358 @@
+package djafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// schemaCandidate is a schema file checked against the latest version of a
+// subject before registering it.
+type schemaCandidate struct {
+	path       string
+	schemaType string
+	schema     string
+	compatible bool
+}
+
+// name is the file name of the candidate, as shown in the status.
+func (c schemaCandidate) name() string {
+	return filepath.Base(c.path)
+}
+
+// schemaFileTypes are the schema types of schema files by extension.
+var schemaFileTypes = map[string]string{
+	".avsc":  SchemaTypeAvro,
+	".proto": SchemaTypeProtobuf,
+	".json":  SchemaTypeJSON,
+}
+
+// SchemaComponent shows the versions of the schema of a subject, one at a
+// time or two side by side as a diff, and checks schema files for
+// compatibility before registering them.
+type SchemaComponent struct {
+	viewport viewport.Model
+	registry *SchemaRegistry
+	ctx      context.Context
+	subject  Subject
+	versions []int
+	loaded   map[int]SubjectVersion
+	// current is the index of the version shown, base the index of the
+	// version it is compared to in the diff
+	current int
+	base    int
+	diff    bool
+	// checkInput is shown while entering the path of a schema file
+	checkInput textinput.Model
+	checking   bool
+	candidate  *schemaCandidate
+	status     string
+	width      int
+	height     int
+}
+
+func NewSchemaComponent(ctx context.Context, registry *SchemaRegistry, subject Subject, size tea.WindowSizeMsg) SchemaComponent {
+	c := SchemaComponent{
+		viewport:   viewport.New(0, 0),
+		registry:   registry,
+		ctx:        ctx,
+		subject:    subject,
+		loaded:     map[int]SubjectVersion{},
+		checkInput: newTextInput(),
+		status:     "Loading versions ...",
+	}
+	c.checkInput.CursorStyle = cursorStyle
+	c.checkInput.PromptStyle = focusedStyle
+	c.checkInput.TextStyle = focusedStyle
+	c.checkInput.CharLimit = 1024
+	c.checkInput.Placeholder = "path of a schema file (.avsc, .proto or .json)"
+	c.SetSize(size)
+
+	return c
+}
+
+func (c SchemaComponent) Init() tea.Cmd {
+	return c.loadVersions()
+}
+
+func (c *SchemaComponent) SetSize(size tea.WindowSizeMsg) {
+	c.width = size.Width
+	c.height = size.Height
+	c.viewport.Width = c.width
+	c.viewport.Height = atLeast(c.height-5, 1)
+	c.refresh()
+}
+
+func (c SchemaComponent) loadVersions() tea.Cmd {
+	registry, ctx, subject := c.registry, c.ctx, c.subject.Name
+	return func() tea.Msg {
+		versions, err := registry.Versions(ctx, subject)
+		return SchemaVersionsMsg{subject, versions, err}
+	}
+}
+
+// load returns a command loading the version at index, unless it was loaded
+// before.
+func (c SchemaComponent) load(index int) tea.Cmd {
+	if index < 0 || index >= len(c.versions) {
+		return nil
+	}
+	version := c.versions[index]
+	if _, ok := c.loaded[version]; ok {
+		return nil
+	}
+	registry, ctx, subject := c.registry, c.ctx, c.subject.Name
+	return func() tea.Msg {
+		v, err := registry.SubjectVersion(ctx, subject, version)
+		return SchemaVersionMsg{subject, v, err}
+	}
+}
+
+// show moves to the versions at current and base and loads them.
+func (c *SchemaComponent) show(current int, base int) tea.Cmd {
+	c.current = clamp(current, 0, len(c.versions)-1)
+	c.base = clamp(base, 0, len(c.versions)-1)
+	c.refresh()
+	c.viewport.GotoTop()
+	return tea.Batch(c.load(c.current), c.load(c.base))
+}
+
+func clamp(value int, minimum int, maximum int) int {
+	if value > maximum {
+		value = maximum
+	}
+	return atLeast(value, minimum)
+}
+
+// refresh renders the version shown or the diff into the viewport.
+func (c *SchemaComponent) refresh() {
+	if len(c.versions) == 0 {
+		c.viewport.SetContent("")
+		return
+	}
+	current, ok := c.loaded[c.versions[c.current]]
+	if !ok {
+		c.viewport.SetContent("Loading ...")
+		return
+	}
+	if !c.diff {
+		c.viewport.SetContent(highlightSchema(current))
+		return
+	}
+	base, ok := c.loaded[c.versions[c.base]]
+	if !ok {
+		c.viewport.SetContent("Loading ...")
+		return
+	}
+	lines := diffLines(formatSchema(base), formatSchema(current))
+	c.viewport.SetContent(renderDiff(lines, atLeast((c.width-3)/2, 10)))
+}
+
+func (c SchemaComponent) Update(msg tea.Msg) (SchemaComponent, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.SetSize(msg)
+		return c, nil
+	case SchemaVersionsMsg:
+		if msg.subject != c.subject.Name {
+			return c, nil
+		}
+		if msg.err != nil {
+			c.status = fmt.Sprintf("Failed to load versions: %s", msg.err)
+			return c, nil
+		}
+		// keeps the status of a registration, after which the versions are
+		// reloaded
+		if c.versions == nil {
+			c.status = ""
+		}
+		c.versions = msg.versions
+		if len(c.versions) == 0 {
+			c.status = "The subject has no versions."
+			return c, nil
+		}
+		return c, c.show(len(c.versions)-1, len(c.versions)-2)
+	case SchemaVersionMsg:
+		if msg.subject != c.subject.Name {
+			return c, nil
+		}
+		if msg.err != nil {
+			c.status = fmt.Sprintf("Failed to load version: %s", msg.err)
+			return c, nil
+		}
+		c.loaded[msg.version.Version] = msg.version
+		c.refresh()
+		return c, nil
+	case CompatibilityCheckedMsg:
+		if msg.err != nil {
+			c.status = fmt.Sprintf("Failed to check %s: %s", msg.candidate.name(), msg.err)
+			return c, nil
+		}
+		c.candidate = &msg.candidate
+		if msg.candidate.compatible {
+			c.status = fmt.Sprintf("%s is compatible with %s (%s), press r to register it.", msg.candidate.name(), c.subject.Name, c.subject.Compatibility)
+		} else {
+			c.status = fmt.Sprintf("%s is not compatible with %s (%s): %s", msg.candidate.name(), c.subject.Name, c.subject.Compatibility, strings.Join(msg.messages, "; "))
+		}
+		return c, nil
+	case SchemaRegisteredMsg:
+		if msg.err != nil {
+			c.status = fmt.Sprintf("Failed to register %s: %s", msg.candidate.name(), msg.err)
+			return c, nil
+		}
+		c.candidate = nil
+		c.status = fmt.Sprintf("Registered %s with id %d.", msg.candidate.name(), msg.id)
+		return c, c.loadVersions()
+	case tea.KeyMsg:
+		if c.checking {
+			return c.updateCheckInput(msg)
+		}
+		switch msg.String() {
+		case ESC:
+			return c, func() tea.Msg { return SchemaClose{} }
+		case "left", "h":
+			return c, c.show(c.current-1, c.base-1)
+		case "right", "l":
+			return c, c.show(c.current+1, c.base+1)
+		case "[":
+			return c, c.show(c.current, c.base-1)
+		case "]":
+			return c, c.show(c.current, c.base+1)
+		case "d":
+			c.diff = !c.diff
+			return c, c.show(c.current, c.base)
+		case "c":
+			c.checking = true
+			c.checkInput.SetValue("")
+			return c, c.checkInput.Focus()
+		case "r":
+			if c.candidate != nil && c.candidate.compatible {
+				c.status = fmt.Sprintf("Registering %s ...", c.candidate.name())
+				return c, c.register(*c.candidate)
+			}
+			return c, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	c.viewport, cmd = c.viewport.Update(msg)
+	return c, cmd
+}
+
+func (c SchemaComponent) updateCheckInput(msg tea.KeyMsg) (SchemaComponent, tea.Cmd) {
+	switch msg.String() {
+	case ESC:
+		c.checking = false
+		c.checkInput.Blur()
+		return c, nil
+	case "enter":
+		candidate, err := c.readCandidate(strings.TrimSpace(c.checkInput.Value()))
+		if err != nil {
+			c.status = err.Error()
+			return c, nil
+		}
+		c.checking = false
+		c.checkInput.Blur()
+		c.candidate = nil
+		c.status = fmt.Sprintf("Checking %s ...", candidate.name())
+		return c, c.check(candidate)
+	}
+
+	var cmd tea.Cmd
+	c.checkInput, cmd = c.checkInput.Update(msg)
+	return c, cmd
+}
+
+// readCandidate reads a schema file, whose type is taken from its extension
+// or else from the latest version of the subject.
+func (c SchemaComponent) readCandidate(path string) (schemaCandidate, error) {
+	if path == "" {
+		return schemaCandidate{}, fmt.Errorf("Enter the schema file to check.")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return schemaCandidate{}, fmt.Errorf("Failed to read schema file: %w", err)
+	}
+
+	schemaType, ok := schemaFileTypes[strings.ToLower(filepath.Ext(path))]
+	if !ok && len(c.versions) > 0 {
+		schemaType = c.loaded[c.versions[len(c.versions)-1]].Type
+	}
+	return schemaCandidate{path: path, schemaType: schemaType, schema: string(content)}, nil
+}
+
+func (c SchemaComponent) check(candidate schemaCandidate) tea.Cmd {
+	registry, ctx, subject := c.registry, c.ctx, c.subject.Name
+	return func() tea.Msg {
+		compatible, messages, err := registry.CheckCompatibility(ctx, subject, candidate.schemaType, candidate.schema)
+		candidate.compatible = compatible
+		return CompatibilityCheckedMsg{candidate, messages, err}
+	}
+}
+
+func (c SchemaComponent) register(candidate schemaCandidate) tea.Cmd {
+	registry, ctx, subject := c.registry, c.ctx, c.subject.Name
+	return func() tea.Msg {
+		id, err := registry.Register(ctx, subject, candidate.schemaType, candidate.schema)
+		return SchemaRegisteredMsg{candidate, id, err}
+	}
+}
+
+func (c SchemaComponent) title() string {
+	if len(c.versions) == 0 {
+		return fmt.Sprintf("Schema of %s", c.subject.Name)
+	}
+	current := c.versions[c.current]
+	if c.diff {
+		return fmt.Sprintf("Diff of %s v%d → v%d", c.subject.Name, c.versions[c.base], current)
+	}
+	details := []string{fmt.Sprintf("%d of %d", c.current+1, len(c.versions))}
+	if v, ok := c.loaded[current]; ok {
+		details = append(details, fmt.Sprintf("id %d", v.ID), v.Type)
+	}
+	details = append(details, c.subject.Compatibility)
+	return fmt.Sprintf("Schema of %s v%d (%s)", c.subject.Name, current, strings.Join(details, " · "))
+}
+
+func (c SchemaComponent) View() string {
+	title := titleStyle.Render(c.title())
+	status := helpStyle.Copy().MaxWidth(c.width).Render(c.status)
+	help := helpStyle.Render("←/→: version • d: diff • [/]: diff against • c: check file • esc: back")
+	if c.candidate != nil && c.candidate.compatible {
+		help = helpStyle.Render("←/→: version • d: diff • [/]: diff against • c: check file • r: register • esc: back")
+	}
+	if c.checking {
+		help = c.checkInput.View()
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, status, "", c.viewport.View(), help)
+}
+
+// formatSchema indents avro and JSON schemas, so they can be compared line by
+// line.
+func formatSchema(v SubjectVersion) string {
+	if v.Type == SchemaTypeProtobuf {
+		return strings.TrimSuffix(v.Schema, "\n")
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(v.Schema), "", "  "); err != nil {
+		return v.Schema
+	}
+	return b.String()
+}
+
+func highlightSchema(v SubjectVersion) string {
+	if v.Type == SchemaTypeProtobuf {
+		return highlight(formatSchema(v), "protobuf")
+	}
+	return highlightJSON(formatSchema(v))
+}
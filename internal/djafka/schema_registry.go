@@ -0,0 +1,557 @@
+package djafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/linker"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+	SchemaTypeJSON     = "JSON"
+
+	// schemaRegistryTimeout limits every request to the registry
+	schemaRegistryTimeout = 10 * time.Second
+	// wireHeaderSize is the magic byte and the schema id preceding records
+	// in the Confluent wire format
+	wireHeaderSize = 5
+)
+
+// SchemaRegistryConfig is the Confluent Schema Registry of a connection. The
+// password may reference a secret like the passwords of Security.
+type SchemaRegistryConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+func (c *SchemaRegistryConfig) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL like http://localhost:8081")
+	}
+	return nil
+}
+
+// RegistrySchema is a schema registered in a schema registry.
+type RegistrySchema struct {
+	ID         int
+	Type       string
+	Schema     string
+	References []SchemaReference
+	// Subject and Version are the first subject the schema is registered
+	// under, empty if the registry does not tell
+	Subject string
+	Version int
+
+	avro  *goavro.Codec
+	proto protoreflect.FileDescriptor
+}
+
+// SchemaReference is a schema imported by another one.
+type SchemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Name returns the subject and version of the schema, or its id if the
+// subject is unknown.
+func (s *RegistrySchema) Name() string {
+	if s.Subject == "" {
+		return fmt.Sprintf("id %d", s.ID)
+	}
+	return fmt.Sprintf("%s v%d", s.Subject, s.Version)
+}
+
+// registryEntry is a cached schema, or why it could not be loaded.
+type registryEntry struct {
+	schema *RegistrySchema
+	err    error
+}
+
+// SchemaRegistry is a client of a Confluent Schema Registry. Schemas are
+// immutable, so they are cached by id for the lifetime of the client.
+type SchemaRegistry struct {
+	config SchemaRegistryConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	schemas map[int]registryEntry
+}
+
+// NewSchemaRegistry returns a client of the registry of config, whose secrets
+// must have been resolved.
+func NewSchemaRegistry(config SchemaRegistryConfig) *SchemaRegistry {
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	return &SchemaRegistry{
+		config:  config,
+		client:  &http.Client{Timeout: schemaRegistryTimeout},
+		schemas: map[int]registryEntry{},
+	}
+}
+
+// registryError is an error response of the registry.
+type registryError struct {
+	Status    int
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func (e *registryError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Schema registry responded with %d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("Schema registry responded with %d: %s", e.Status, e.Message)
+}
+
+// get requests path from the registry and decodes the JSON response into v.
+func (r *SchemaRegistry) get(ctx context.Context, path string, v any) error {
+	return r.do(ctx, http.MethodGet, path, nil, v)
+}
+
+// post sends body as JSON to path and decodes the JSON response into v.
+func (r *SchemaRegistry) post(ctx context.Context, path string, body any, v any) error {
+	return r.do(ctx, http.MethodPost, path, body, v)
+}
+
+func (r *SchemaRegistry) do(ctx context.Context, method string, path string, body any, v any) error {
+	var content io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("Failed to encode schema registry request: %w", err)
+		}
+		content = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.config.URL+path, content)
+	if err != nil {
+		return fmt.Errorf("Failed to create schema registry request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+	if r.config.Username != "" {
+		req.SetBasicAuth(r.config.Username, r.config.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to reach schema registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		regErr := &registryError{Status: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(regErr)
+		return regErr
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("Failed to decode schema registry response of '%s': %w", path, err)
+	}
+	return nil
+}
+
+// Schema returns the schema with the given id, loading it from the registry
+// on first use. Schemas which don't exist or can't be parsed are remembered
+// as well, only failed requests are tried again.
+func (r *SchemaRegistry) Schema(ctx context.Context, id int) (*RegistrySchema, error) {
+	if schema, err, ok := r.Cached(id); ok {
+		return schema, err
+	}
+
+	schema, err := r.load(ctx, id)
+	if !transient(err) {
+		r.mu.Lock()
+		r.schemas[id] = registryEntry{schema, err}
+		r.mu.Unlock()
+	}
+	return schema, err
+}
+
+// transient reports whether a request to the registry failed for a reason
+// that may go away, like an unreachable or overloaded registry.
+func transient(err error) bool {
+	var urlErr *url.Error
+	var regErr *registryError
+	return errors.As(err, &urlErr) || errors.As(err, &regErr) && regErr.Status >= http.StatusInternalServerError
+}
+
+// Cached returns the schema with the given id if it was loaded before.
+func (r *SchemaRegistry) Cached(id int) (*RegistrySchema, error, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.schemas[id]
+	return entry.schema, entry.err, ok
+}
+
+func (r *SchemaRegistry) load(ctx context.Context, id int) (*RegistrySchema, error) {
+	var response struct {
+		SchemaType string            `json:"schemaType"`
+		Schema     string            `json:"schema"`
+		References []SchemaReference `json:"references"`
+	}
+	if err := r.get(ctx, fmt.Sprintf("/schemas/ids/%d", id), &response); err != nil {
+		return nil, err
+	}
+
+	schema := &RegistrySchema{ID: id, Type: response.SchemaType, Schema: response.Schema, References: response.References}
+	if schema.Type == "" {
+		schema.Type = SchemaTypeAvro
+	}
+
+	// older registries don't know the versions of an id
+	var versions []struct {
+		Subject string `json:"subject"`
+		Version int    `json:"version"`
+	}
+	if err := r.get(ctx, fmt.Sprintf("/schemas/ids/%d/versions", id), &versions); err == nil && len(versions) > 0 {
+		schema.Subject, schema.Version = versions[0].Subject, versions[0].Version
+	}
+
+	if err := r.compile(ctx, schema); err != nil {
+		return nil, fmt.Errorf("Failed to parse schema %d: %w", id, err)
+	}
+	return schema, nil
+}
+
+// compile prepares the decoder of schema.
+func (r *SchemaRegistry) compile(ctx context.Context, schema *RegistrySchema) error {
+	switch schema.Type {
+	case SchemaTypeAvro:
+		if len(schema.References) > 0 {
+			return fmt.Errorf("avro schemas with references are not supported")
+		}
+		codec, err := goavro.NewCodec(schema.Schema)
+		if err != nil {
+			return err
+		}
+		schema.avro = codec
+	case SchemaTypeProtobuf:
+		sources := map[string]string{}
+		if err := r.referencedSources(ctx, schema.References, sources); err != nil {
+			return err
+		}
+		const name = "registry_schema.proto"
+		sources[name] = schema.Schema
+		fd, err := compileProto(ctx, sources, name)
+		if err != nil {
+			return err
+		}
+		schema.proto = fd
+	case SchemaTypeJSON:
+	default:
+		return fmt.Errorf("unknown schema type '%s'", schema.Type)
+	}
+	return nil
+}
+
+// referencedSources adds the sources of the referenced schemas and their
+// references to sources, by their import name.
+func (r *SchemaRegistry) referencedSources(ctx context.Context, references []SchemaReference, sources map[string]string) error {
+	for _, ref := range references {
+		if _, ok := sources[ref.Name]; ok {
+			continue
+		}
+		var response struct {
+			Schema     string            `json:"schema"`
+			References []SchemaReference `json:"references"`
+		}
+		path := fmt.Sprintf("%s/versions/%d", subjectPath(ref.Subject), ref.Version)
+		if err := r.get(ctx, path, &response); err != nil {
+			return err
+		}
+		sources[ref.Name] = response.Schema
+		if err := r.referencedSources(ctx, response.References, sources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileProto compiles the file called name of sources, which may import
+// the other sources and the well-known types.
+func compileProto(ctx context.Context, sources map[string]string, name string) (protoreflect.FileDescriptor, error) {
+	files, err := compileProtos(ctx, sources, name)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// compileProtos compiles the files called names of sources.
+func compileProtos(ctx context.Context, sources map[string]string, names ...string) (linker.Files, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	return compiler.Compile(ctx, names...)
+}
+
+// Prefetch loads the schemas of all keys and values of records in the wire
+// format, so they can be decoded without waiting for the registry.
+func (r *SchemaRegistry) Prefetch(ctx context.Context, records []Record) {
+	for _, record := range records {
+		for _, data := range [][]byte{record.Key, record.Value} {
+			if id, ok := wireSchemaID(data); ok {
+				r.Schema(ctx, id)
+			}
+		}
+	}
+}
+
+// wireSchemaID returns the schema id of data in the Confluent wire format.
+func wireSchemaID(data []byte) (int, bool) {
+	if len(data) < wireHeaderSize || data[0] != 0 {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint32(data[1:wireHeaderSize])), true
+}
+
+// registryDeserializer decodes data in the Confluent wire format with the
+// schemas of the registry, and anything else with fallback.
+type registryDeserializer struct {
+	registry *SchemaRegistry
+	fallback Deserializer
+}
+
+func (registryDeserializer) Format() string { return FormatRegistry }
+
+func (d registryDeserializer) Deserialize(data []byte) (Decoded, error) {
+	id, ok := wireSchemaID(data)
+	if !ok {
+		return d.fallback.Deserialize(data)
+	}
+	schema, err, ok := d.registry.Cached(id)
+	if !ok {
+		return Decoded{}, fmt.Errorf("schema %d could not be loaded from the registry", id)
+	} else if err != nil {
+		return Decoded{}, err
+	}
+
+	decoded, err := schema.decode(data[wireHeaderSize:])
+	if err != nil {
+		return Decoded{}, fmt.Errorf("%s: %w", schema.Name(), err)
+	}
+	decoded.Schema = schema.Name()
+	return decoded, nil
+}
+
+// decode decodes the payload following the wire header.
+func (s *RegistrySchema) decode(payload []byte) (Decoded, error) {
+	switch s.Type {
+	case SchemaTypeAvro:
+		return decodeAvro(s.avro, payload)
+	case SchemaTypeProtobuf:
+		indexes, n, err := consumeMessageIndexes(payload)
+		if err != nil {
+			return Decoded{}, err
+		}
+		md, err := messageByIndexes(s.proto, indexes)
+		if err != nil {
+			return Decoded{}, err
+		}
+		return decodeProtobufMessage(md, payload[n:])
+	case SchemaTypeJSON:
+		return jsonDeserializer{}.Deserialize(payload)
+	}
+	return Decoded{}, fmt.Errorf("unknown schema type '%s'", s.Type)
+}
+
+// consumeMessageIndexes reads the path to the message type of a protobuf
+// payload, a zigzag encoded count followed by the indexes. A single 0 is
+// short for the first message of the file.
+func consumeMessageIndexes(payload []byte) ([]int, int, error) {
+	count, n := protowire.ConsumeVarint(payload)
+	if n < 0 {
+		return nil, 0, fmt.Errorf("invalid message indexes: %w", protowire.ParseError(n))
+	}
+	read := n
+	length := protowire.DecodeZigZag(count)
+	if length == 0 {
+		return []int{0}, read, nil
+	}
+	if length < 0 || length > 100 {
+		return nil, 0, fmt.Errorf("invalid number of message indexes %d", length)
+	}
+
+	indexes := make([]int, length)
+	for i := range indexes {
+		index, n := protowire.ConsumeVarint(payload[read:])
+		if n < 0 {
+			return nil, 0, fmt.Errorf("invalid message indexes: %w", protowire.ParseError(n))
+		}
+		read += n
+		indexes[i] = int(protowire.DecodeZigZag(index))
+	}
+	return indexes, read, nil
+}
+
+// messageByIndexes returns the message type at the path of indexes, starting
+// with the top level messages of fd.
+func messageByIndexes(fd protoreflect.FileDescriptor, indexes []int) (protoreflect.MessageDescriptor, error) {
+	messages := fd.Messages()
+	var md protoreflect.MessageDescriptor
+	for _, index := range indexes {
+		if index < 0 || index >= messages.Len() {
+			return nil, fmt.Errorf("schema has no message at index %v", indexes)
+		}
+		md = messages.Get(index)
+		messages = md.Messages()
+	}
+	return md, nil
+}
+
+// Subject is a subject of the registry with its compatibility level.
+type Subject struct {
+	Name          string
+	Compatibility string
+	// Global is set if the subject has no level of its own and uses the
+	// global one
+	Global bool
+}
+
+// SubjectVersion is a version of the schema of a subject.
+type SubjectVersion struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	ID      int    `json:"id"`
+	Type    string `json:"schemaType"`
+	Schema  string `json:"schema"`
+}
+
+// subjectPath returns the path of a subject, escaped as subjects may contain
+// any character.
+func subjectPath(subject string) string {
+	return "/subjects/" + url.PathEscape(subject)
+}
+
+// Subjects returns all subjects with their compatibility level, sorted by
+// name.
+func (r *SchemaRegistry) Subjects(ctx context.Context) ([]Subject, error) {
+	var names []string
+	if err := r.get(ctx, "/subjects", &names); err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var global struct {
+		Level string `json:"compatibilityLevel"`
+	}
+	if err := r.get(ctx, "/config", &global); err != nil {
+		return nil, err
+	}
+
+	subjects := make([]Subject, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	// limits the concurrent requests to the registry
+	slots := make(chan struct{}, 8)
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			subjects[i] = Subject{Name: name, Compatibility: global.Level, Global: true}
+			var config struct {
+				Level string `json:"compatibilityLevel"`
+			}
+			err := r.get(ctx, "/config/"+url.PathEscape(name), &config)
+			var regErr *registryError
+			if errors.As(err, &regErr) && regErr.Status == http.StatusNotFound {
+				return
+			} else if err != nil {
+				errs[i] = err
+				return
+			}
+			subjects[i].Compatibility, subjects[i].Global = config.Level, false
+		}(i, name)
+	}
+	wg.Wait()
+
+	return subjects, errors.Join(errs...)
+}
+
+// Versions returns the versions of subject in ascending order.
+func (r *SchemaRegistry) Versions(ctx context.Context, subject string) ([]int, error) {
+	var versions []int
+	if err := r.get(ctx, subjectPath(subject)+"/versions", &versions); err != nil {
+		return nil, err
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// SubjectVersion returns a version of the schema of subject.
+func (r *SchemaRegistry) SubjectVersion(ctx context.Context, subject string, version int) (SubjectVersion, error) {
+	var v SubjectVersion
+	if err := r.get(ctx, fmt.Sprintf("%s/versions/%d", subjectPath(subject), version), &v); err != nil {
+		return v, err
+	}
+	if v.Type == "" {
+		v.Type = SchemaTypeAvro
+	}
+	return v, nil
+}
+
+// candidateSchema is a schema sent to the registry.
+type candidateSchema struct {
+	Schema string `json:"schema"`
+	Type   string `json:"schemaType,omitempty"`
+}
+
+func newCandidate(schemaType string, schema string) candidateSchema {
+	// avro is the default and not known to old registries
+	if schemaType == SchemaTypeAvro {
+		schemaType = ""
+	}
+	return candidateSchema{schema, schemaType}
+}
+
+// CheckCompatibility checks whether schema can be registered as the next
+// version of subject. If not, the reasons given by the registry are returned.
+func (r *SchemaRegistry) CheckCompatibility(ctx context.Context, subject string, schemaType string, schema string) (bool, []string, error) {
+	var response struct {
+		Compatible bool     `json:"is_compatible"`
+		Messages   []string `json:"messages"`
+	}
+	path := "/compatibility" + subjectPath(subject) + "/versions/latest?verbose=true"
+	if err := r.post(ctx, path, newCandidate(schemaType, schema), &response); err != nil {
+		return false, nil, err
+	}
+	return response.Compatible, response.Messages, nil
+}
+
+// Register registers schema as the next version of subject and returns its
+// id.
+func (r *SchemaRegistry) Register(ctx context.Context, subject string, schemaType string, schema string) (int, error) {
+	var response struct {
+		ID int `json:"id"`
+	}
+	if err := r.post(ctx, subjectPath(subject)+"/versions", newCandidate(schemaType, schema), &response); err != nil {
+		return 0, err
+	}
+	return response.ID, nil
+}
@@ -0,0 +1,258 @@
+package djafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	testCommonProto = `syntax = "proto3";
+package shop;
+
+message Money {
+  string currency = 1;
+  int64 cents = 2;
+}
+`
+	testOrderProto = `syntax = "proto3";
+package shop;
+
+import "common.proto";
+
+message Order {
+  int64 id = 1;
+  Money total = 2;
+}
+
+message Refund {
+  int64 order_id = 1;
+  string reason = 2;
+}
+`
+	testAvroSchemaV2 = `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "long"}]}`
+)
+
+// testRegistry is a stand-in for a Confluent Schema Registry serving an avro
+// schema with id 1, a protobuf schema with a reference with id 2 and a JSON
+// schema with id 3. The subject orders-value has two versions, of which
+// only schemas with a status field are compatible. It counts the requests it
+// served.
+func testRegistry(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	requests := &atomic.Int64{}
+	responses := map[string]string{
+		"/schemas/ids/1":          `{"schema": ` + quoteJSON(testAvroSchema) + `}`,
+		"/schemas/ids/1/versions": `[{"subject": "orders-value", "version": 3}]`,
+		"/schemas/ids/2": `{"schemaType": "PROTOBUF", "schema": ` + quoteJSON(testOrderProto) + `,
+			"references": [{"name": "common.proto", "subject": "common", "version": 1}]}`,
+		"/schemas/ids/2/versions":                 `[{"subject": "orders-proto-value", "version": 1}]`,
+		"/subjects/common/versions/1":             `{"subject": "common", "version": 1, "schema": ` + quoteJSON(testCommonProto) + `}`,
+		"/schemas/ids/3":                          `{"schemaType": "JSON", "schema": "{\"type\": \"object\"}"}`,
+		"/subjects":                               `["orders-value", "common", "orders-proto-value"]`,
+		"/config":                                 `{"compatibilityLevel": "BACKWARD"}`,
+		"/config/orders-value":                    `{"compatibilityLevel": "FULL"}`,
+		"/subjects/common/versions":               `[1]`,
+		"/subjects/orders-proto-value/versions":   `[1]`,
+		"/subjects/orders-proto-value/versions/1": `{"subject": "orders-proto-value", "version": 1, "id": 2, "schemaType": "PROTOBUF", "schema": ` + quoteJSON(testOrderProto) + `}`,
+		"/subjects/orders-value/versions":         `[3, 2]`,
+		"/subjects/orders-value/versions/2":       `{"subject": "orders-value", "version": 2, "id": 4, "schema": ` + quoteJSON(testAvroSchemaV2) + `}`,
+		"/subjects/orders-value/versions/3":       `{"subject": "orders-value", "version": 3, "id": 1, "schema": ` + quoteJSON(testAvroSchema) + `}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if user, password, ok := r.BasicAuth(); !ok || user != "djafka" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error_code": 401, "message": "Unauthorized"}`))
+			return
+		}
+		if r.Method == http.MethodPost {
+			var candidate struct {
+				Schema string `json:"schema"`
+			}
+			json.NewDecoder(r.Body).Decode(&candidate)
+			compatible := strings.Contains(candidate.Schema, `"status"`)
+			switch r.URL.Path {
+			case "/compatibility/subjects/orders-value/versions/latest":
+				if compatible {
+					w.Write([]byte(`{"is_compatible": true}`))
+				} else {
+					w.Write([]byte(`{"is_compatible": false, "messages": ["READER_FIELD_MISSING_DEFAULT_VALUE: status"]}`))
+				}
+				return
+			case "/subjects/orders-value/versions":
+				w.Write([]byte(`{"id": 5}`))
+				return
+			}
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code": 40403, "message": "Schema not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func quoteJSON(s string) string {
+	return string(mustMarshal(s))
+}
+
+// wireFormat prefixes payload with the magic byte and the schema id.
+func wireFormat(id int, payload ...[]byte) []byte {
+	data := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(data[1:], uint32(id))
+	for _, p := range payload {
+		data = append(data, p...)
+	}
+	return data
+}
+
+// testOrderMessage encodes a shop.Order of testOrderProto.
+func testOrderMessage() []byte {
+	var money []byte
+	money = protowire.AppendTag(money, 1, protowire.BytesType)
+	money = protowire.AppendString(money, "EUR")
+	money = protowire.AppendTag(money, 2, protowire.VarintType)
+	money = protowire.AppendVarint(money, 1250)
+	var order []byte
+	order = protowire.AppendTag(order, 1, protowire.VarintType)
+	order = protowire.AppendVarint(order, 42)
+	order = protowire.AppendTag(order, 2, protowire.BytesType)
+	order = protowire.AppendBytes(order, money)
+	return order
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	server, requests := testRegistry(t)
+	registry := NewSchemaRegistry(SchemaRegistryConfig{URL: server.URL + "/", Username: "djafka", Password: "secret"})
+
+	order := testOrderMessage()
+	var refund []byte
+	refund = protowire.AppendTag(refund, 1, protowire.VarintType)
+	refund = protowire.AppendVarint(refund, 42)
+	refund = protowire.AppendTag(refund, 2, protowire.BytesType)
+	refund = protowire.AppendString(refund, "damaged")
+
+	tests := []struct {
+		data   []byte
+		line   string
+		schema string
+		err    string
+	}{
+		{wireFormat(1, []byte{0x54, 0x08}, []byte("paid")), `{"id":42,"status":"paid"}`, "orders-value v3", ""},
+		// the first message of the file is written as a single 0
+		{wireFormat(2, []byte{0}, order), `{"id":"42","total":{"currency":"EUR","cents":"1250"}}`, "orders-proto-value v1", ""},
+		// one index, zigzag encoded, selecting the second message
+		{wireFormat(2, []byte{2, 2}, refund), `{"orderId":"42","reason":"damaged"}`, "orders-proto-value v1", ""},
+		{wireFormat(3, []byte(`{"a": [1, 2]}`)), `{"a":[1,2]}`, "id 3", ""},
+		{wireFormat(2, []byte{2, 8}, refund), "", "", "Failed to deserialize as registry: orders-proto-value v1: schema has no message at index [4]"},
+		{wireFormat(9, []byte("?")), "", "", "Failed to deserialize as registry: Schema registry responded with 404: Schema not found"},
+		{[]byte("plain"), "plain", "", ""},
+	}
+
+	records := []Record{}
+	for _, test := range tests {
+		records = append(records, Record{Value: test.data})
+	}
+	registry.Prefetch(context.Background(), records)
+
+	d, err := NewDeserializer(nil, "", registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		decoded := Decode(d, test.data)
+		if test.err != "" {
+			if decoded.Err == nil || decoded.Err.Error() != test.err {
+				t.Errorf("%q: got error %v, want %s", test.data, decoded.Err, test.err)
+			}
+			continue
+		}
+		if decoded.Err != nil {
+			t.Errorf("%q: %s", test.data, decoded.Err)
+		}
+		if decoded.Line != test.line || decoded.Schema != test.schema {
+			t.Errorf("%q: got %s with %q, want %s with %q", test.data, decoded.Line, decoded.Schema, test.line, test.schema)
+		}
+	}
+
+	// schemas, even missing ones, are only requested once
+	served := requests.Load()
+	registry.Prefetch(context.Background(), records)
+	if requests.Load() != served {
+		t.Errorf("Cached schemas were requested again, %d requests instead of %d", requests.Load(), served)
+	}
+
+	unauthorized := NewSchemaRegistry(SchemaRegistryConfig{URL: server.URL})
+	if _, err := unauthorized.Schema(context.Background(), 1); err == nil || !strings.Contains(err.Error(), "401: Unauthorized") {
+		t.Errorf("Expected an unauthorized error, got %v", err)
+	}
+}
+
+func TestSchemaRegistrySubjects(t *testing.T) {
+	server, _ := testRegistry(t)
+	registry := NewSchemaRegistry(SchemaRegistryConfig{URL: server.URL, Username: "djafka", Password: "secret"})
+	ctx := context.Background()
+
+	subjects, err := registry.Subjects(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Subject{
+		{"common", "BACKWARD", true},
+		{"orders-proto-value", "BACKWARD", true},
+		{"orders-value", "FULL", false},
+	}
+	if !reflect.DeepEqual(subjects, want) {
+		t.Errorf("Got subjects %v, want %v", subjects, want)
+	}
+
+	versions, err := registry.Versions(ctx, "orders-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(versions, []int{2, 3}) {
+		t.Errorf("Got versions %v, want [2 3]", versions)
+	}
+	version, err := registry.SubjectVersion(ctx, "orders-value", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.ID != 4 || version.Type != SchemaTypeAvro || version.Schema != testAvroSchemaV2 {
+		t.Errorf("Got version %+v", version)
+	}
+
+	compatible, messages, err := registry.CheckCompatibility(ctx, "orders-value", SchemaTypeAvro, testAvroSchemaV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compatible || len(messages) != 1 {
+		t.Errorf("Expected an incompatible schema with a reason, got %t %v", compatible, messages)
+	}
+	compatible, _, err = registry.CheckCompatibility(ctx, "orders-value", SchemaTypeAvro, testAvroSchema)
+	if err != nil || !compatible {
+		t.Errorf("Expected a compatible schema, got %t %v", compatible, err)
+	}
+
+	id, err := registry.Register(ctx, "orders-value", SchemaTypeAvro, testAvroSchema)
+	if err != nil || id != 5 {
+		t.Errorf("Expected id 5, got %d %v", id, err)
+	}
+	if _, err := registry.Versions(ctx, "missing"); err == nil {
+		t.Error("Expected an error for a missing subject")
+	}
+}
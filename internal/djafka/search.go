@@ -0,0 +1,102 @@
+package djafka
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// Search reads the records of topic within r and sends those matching filter
+// to matches. Every partition is read by its own goroutine, so the matches of
+// the partitions arrive interleaved. It returns once all were read, or on the
+// first error. scanned counts the records read.
+func Search(ctx context.Context, backend KafkaBackend, topic string, r ExportRange, filter Filter, matches chan<- Record, scanned *atomic.Int64) error {
+	partitions, err := searchPartitions(ctx, backend, topic, r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(partitions))
+	for _, partition := range partitions {
+		go func(partition int32) {
+			errs <- searchPartition(ctx, backend, topic, partition, r, filter, matches, scanned)
+		}(partition)
+	}
+
+	// every partition must be done before matches may be left alone
+	var first error
+	for range partitions {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
+
+// searchPartitions returns the partitions of topic to read for r, leaving out
+// those not listed in offsets per partition.
+func searchPartitions(ctx context.Context, backend KafkaBackend, topic string, r ExportRange) ([]int32, error) {
+	metadata, err := backend.GetTopicMetadata(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	count := len(metadata.Partitions)
+	for _, position := range []StartPosition{r.Start, r.End} {
+		if partition, ok := position.unknownPartition(count); position.Mode == SeekOffset && ok {
+			return nil, fmt.Errorf("Topic '%s' has no partition %d.", topic, partition)
+		}
+	}
+
+	partitions := r.Partitions
+	if len(partitions) == 0 {
+		for _, partition := range metadata.Partitions {
+			partitions = append(partitions, partition.ID)
+		}
+	}
+	read := []int32{}
+	for _, partition := range partitions {
+		if partition < 0 || int(partition) >= count {
+			return nil, fmt.Errorf("Topic '%s' has no partition %d.", topic, partition)
+		}
+		if _, ok := r.Start.offset(partition); r.Start.Mode == SeekOffset && !ok {
+			continue
+		}
+		if _, ok := r.End.offset(partition); r.End.Mode == SeekOffset && !ok {
+			continue
+		}
+		read = append(read, partition)
+	}
+	return read, nil
+}
+
+// searchPartition reads partition of topic within r and sends the records
+// matching filter to matches.
+func searchPartition(ctx context.Context, backend KafkaBackend, topic string, partition int32, r ExportRange, filter Filter, matches chan<- Record, scanned *atomic.Int64) error {
+	// records is unbuffered, so every record was received once done is sent
+	records := make(chan Record)
+	done := make(chan error, 1)
+	go func() {
+		done <- backend.ReadRange(ctx, topic, []int32{partition}, r.Start, r.End, records)
+	}()
+
+	for {
+		select {
+		case record := <-records:
+			scanned.Add(1)
+			if !filter.Match(record) {
+				continue
+			}
+			select {
+			case matches <- record:
+			case <-ctx.Done():
+				// ReadRange returns as well
+			}
+		case err := <-done:
+			return err
+		}
+	}
+}
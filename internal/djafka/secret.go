@@ -0,0 +1,103 @@
+package djafka
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+	secretCmdPrefix  = "cmd:"
+)
+
+// SecretError is returned when a secret reference of a connection could not
+// be resolved. It never contains the resolved value.
+type SecretError struct {
+	Connection string
+	Reference  string
+	Err        error
+}
+
+func (e *SecretError) Error() string {
+	return fmt.Sprintf("Failed to resolve secret '%s' of connection '%s': %s", e.Reference, e.Connection, e.Err)
+}
+
+func (e *SecretError) Unwrap() error {
+	return e.Err
+}
+
+// isSecretReference reports whether value points to a secret instead of
+// containing it.
+func isSecretReference(value string) bool {
+	return strings.HasPrefix(value, secretEnvPrefix) ||
+		strings.HasPrefix(value, secretFilePrefix) ||
+		strings.HasPrefix(value, secretCmdPrefix)
+}
+
+// secretProperties are the client properties holding a secret, so their
+// values may be secret references. Other properties are used as is, e.g. a
+// path starting with "file:" or the kinit command.
+var secretProperties = map[string]bool{
+	"sasl.password":                  true,
+	"sasl.oauthbearer.client.secret": true,
+	"ssl.key.password":               true,
+	"ssl.key.pem":                    true,
+	"ssl.keystore.password":          true,
+	"ssl.truststore.password":        true,
+}
+
+// resolveSecret returns the value referenced by "env:NAME", "file:/path" or
+// "cmd:command args", which is run by sh, so arguments may be quoted. Any
+// other value is returned as is.
+func resolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretEnvPrefix):
+		name := strings.TrimPrefix(ref, secretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%s' is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, secretFilePrefix):
+		content, err := os.ReadFile(strings.TrimPrefix(ref, secretFilePrefix))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case strings.HasPrefix(ref, secretCmdPrefix):
+		command := strings.TrimSpace(strings.TrimPrefix(ref, secretCmdPrefix))
+		if command == "" {
+			return "", fmt.Errorf("no command given")
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		// only the first line is used, like git credential helpers and pass do
+		return strings.SplitN(strings.TrimRight(string(output), "\r\n"), "\n", 2)[0], nil
+	}
+
+	return ref, nil
+}
+
+// resolveSecrets returns a copy of the security settings with every secret
+// reference replaced by its value.
+func (s *Security) resolveSecrets(connection string) (*Security, error) {
+	resolved := *s
+	for _, field := range []*string{&resolved.Password, &resolved.KeyPassword, &resolved.ClientSecret} {
+		value, err := resolveSecret(*field)
+		if err != nil {
+			return nil, &SecretError{connection, *field, err}
+		}
+		*field = value
+	}
+
+	return &resolved, nil
+}
@@ -0,0 +1,95 @@
+package djafka
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("DJAFKA_TEST_SECRET", "from-env")
+	t.Setenv("DJAFKA_TEST_EMPTY", "")
+	dir := t.TempDir()
+	file := filepath.Join(dir, "secret")
+	if err := os.WriteFile(file, []byte("from-file\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ref  string
+		want string
+		err  string
+	}{
+		{ref: "plain", want: "plain"},
+		{ref: "", want: ""},
+		{ref: "envy:DJAFKA_TEST_SECRET", want: "envy:DJAFKA_TEST_SECRET"},
+		{ref: "env:DJAFKA_TEST_SECRET", want: "from-env"},
+		{ref: "env:DJAFKA_TEST_EMPTY", want: ""},
+		{ref: "env:DJAFKA_TEST_MISSING", err: "environment variable 'DJAFKA_TEST_MISSING' is not set"},
+		{ref: "file:" + file, want: "from-file"},
+		{ref: "file:" + filepath.Join(dir, "missing"), err: "no such file or directory"},
+		{ref: "cmd:echo from-cmd", want: "from-cmd"},
+		{ref: `cmd:printf '%s\n%s' first second`, want: "first"},
+		{ref: `cmd:echo "from   cmd"`, want: "from   cmd"},
+		{ref: "cmd: ", err: "no command given"},
+		{ref: "cmd:cat " + filepath.Join(dir, "missing"), err: "exit status 1: cat:"},
+		{ref: "cmd:djafka-missing-command", err: "exit status 127"},
+	}
+
+	for _, test := range tests {
+		value, err := resolveSecret(test.ref)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", test.ref, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.ref, err)
+			continue
+		}
+		if value != test.want {
+			t.Errorf("%s: expected %q, got %q", test.ref, test.want, value)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("DJAFKA_TEST_SECRET", "from-env")
+
+	conn := Connection{
+		Name:     "local",
+		Security: &Security{Mechanism: MechanismPlain, Username: "user", Password: "env:DJAFKA_TEST_SECRET"},
+		Properties: map[string]string{
+			"sasl.password":           "env:DJAFKA_TEST_SECRET",
+			"ssl.keystore.location":   "file:/etc/kafka/keystore.p12",
+			"sasl.kerberos.kinit.cmd": "cmd:kinit -kt /etc/krb5.keytab djafka",
+			"client.id":               "djafka",
+		},
+	}
+	resolved, err := conn.ResolveSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Security.Password != "from-env" || resolved.Properties["sasl.password"] != "from-env" || resolved.Properties["client.id"] != "djafka" {
+		t.Errorf("Secrets were not resolved: %+v %v", resolved.Security, resolved.Properties)
+	}
+	if resolved.Properties["ssl.keystore.location"] != "file:/etc/kafka/keystore.p12" || resolved.Properties["sasl.kerberos.kinit.cmd"] != "cmd:kinit -kt /etc/krb5.keytab djafka" {
+		t.Errorf("A property without a secret was resolved: %v", resolved.Properties)
+	}
+	if conn.Security.Password != "env:DJAFKA_TEST_SECRET" || conn.Properties["sasl.password"] != "env:DJAFKA_TEST_SECRET" {
+		t.Error("Resolving secrets changed the connection")
+	}
+
+	conn.Security.KeyPassword = "env:DJAFKA_TEST_MISSING"
+	_, err = conn.ResolveSecrets()
+	var secretErr *SecretError
+	if !errors.As(err, &secretErr) || secretErr.Reference != "env:DJAFKA_TEST_MISSING" || secretErr.Connection != "local" {
+		t.Errorf("Expected a SecretError for the key password, got %v", err)
+	}
+	if strings.Contains(err.Error(), "from-env") {
+		t.Errorf("The error contains a resolved secret: %s", err)
+	}
+}
@@ -0,0 +1,193 @@
+package djafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+	ProtocolPlaintext     = "PLAINTEXT"
+	ProtocolSSL           = "SSL"
+	ProtocolSASLPlaintext = "SASL_PLAINTEXT"
+	ProtocolSASLSSL       = "SASL_SSL"
+
+	MechanismPlain       = "PLAIN"
+	MechanismScramSHA256 = "SCRAM-SHA-256"
+	MechanismScramSHA512 = "SCRAM-SHA-512"
+	MechanismOAuthBearer = "OAUTHBEARER"
+)
+
+// Security describes how a connection authenticates against the cluster. Only
+// the fields required by the chosen protocol and mechanism have to be set.
+type Security struct {
+	Protocol  string `json:"protocol,omitempty"`
+	Mechanism string `json:"mechanism,omitempty"`
+
+	// SASL PLAIN and SCRAM
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// SSL and mTLS
+	CALocation          string `json:"caLocation,omitempty"`
+	CertificateLocation string `json:"certificateLocation,omitempty"`
+	KeyLocation         string `json:"keyLocation,omitempty"`
+	KeyPassword         string `json:"keyPassword,omitempty"`
+
+	// SASL OAUTHBEARER using the OIDC client credentials flow
+	TokenEndpoint string `json:"tokenEndpoint,omitempty"`
+	ClientId      string `json:"clientId,omitempty"`
+	ClientSecret  string `json:"clientSecret,omitempty"`
+	Scope         string `json:"scope,omitempty"`
+}
+
+// protocol returns the configured security protocol or derives it from the
+// mechanism and certificates when it was left out.
+func (s *Security) protocol() string {
+	if s.Protocol != "" {
+		return strings.ToUpper(s.Protocol)
+	}
+	if s.Mechanism != "" {
+		return ProtocolSASLSSL
+	}
+	if s.CALocation != "" || s.CertificateLocation != "" {
+		return ProtocolSSL
+	}
+
+	return ProtocolPlaintext
+}
+
+// Describe returns a short human readable summary like "SASL_SSL/SCRAM-SHA-512".
+func (s *Security) Describe() string {
+	if s == nil {
+		return ProtocolPlaintext
+	}
+	if s.Mechanism == "" {
+		return s.protocol()
+	}
+
+	return s.protocol() + "/" + strings.ToUpper(s.Mechanism)
+}
+
+// properties translates the security settings into librdkafka properties.
+func (s *Security) properties() (kafka.ConfigMap, error) {
+	props := kafka.ConfigMap{}
+	protocol := s.protocol()
+	mechanism := strings.ToUpper(s.Mechanism)
+
+	switch protocol {
+	case ProtocolPlaintext, ProtocolSSL:
+		if mechanism != "" {
+			return nil, fmt.Errorf("mechanism '%s' requires protocol %s or %s", s.Mechanism, ProtocolSASLPlaintext, ProtocolSASLSSL)
+		}
+	case ProtocolSASLPlaintext, ProtocolSASLSSL:
+		if mechanism == "" {
+			return nil, fmt.Errorf("protocol %s requires a mechanism", protocol)
+		}
+	default:
+		return nil, fmt.Errorf("unknown security protocol '%s'", s.Protocol)
+	}
+	props["security.protocol"] = protocol
+
+	switch mechanism {
+	case "":
+	case MechanismPlain, MechanismScramSHA256, MechanismScramSHA512:
+		if s.Username == "" || s.Password == "" {
+			return nil, fmt.Errorf("mechanism %s requires a username and a password", mechanism)
+		}
+		props["sasl.mechanisms"] = mechanism
+		props["sasl.username"] = s.Username
+		props["sasl.password"] = s.Password
+	case MechanismOAuthBearer:
+		if s.TokenEndpoint == "" || s.ClientId == "" || s.ClientSecret == "" {
+			return nil, fmt.Errorf("mechanism %s requires a token endpoint, a client id and a client secret", mechanism)
+		}
+		props["sasl.mechanisms"] = mechanism
+		props["sasl.oauthbearer.method"] = "oidc"
+		props["sasl.oauthbearer.token.endpoint.url"] = s.TokenEndpoint
+		props["sasl.oauthbearer.client.id"] = s.ClientId
+		props["sasl.oauthbearer.client.secret"] = s.ClientSecret
+		if s.Scope != "" {
+			props["sasl.oauthbearer.scope"] = s.Scope
+		}
+	default:
+		return nil, fmt.Errorf("unknown SASL mechanism '%s'", s.Mechanism)
+	}
+
+	if (s.CertificateLocation == "") != (s.KeyLocation == "") {
+		return nil, fmt.Errorf("client certificates require both a certificate and a key location")
+	}
+	if s.CALocation != "" || s.CertificateLocation != "" {
+		if protocol != ProtocolSSL && protocol != ProtocolSASLSSL {
+			return nil, fmt.Errorf("certificates require protocol %s or %s", ProtocolSSL, ProtocolSASLSSL)
+		}
+	}
+	if s.CALocation != "" {
+		props["ssl.ca.location"] = s.CALocation
+	}
+	if s.CertificateLocation != "" {
+		props["ssl.certificate.location"] = s.CertificateLocation
+		props["ssl.key.location"] = s.KeyLocation
+	}
+	if s.KeyPassword != "" {
+		props["ssl.key.password"] = s.KeyPassword
+	}
+
+	return props, nil
+}
+
+// isHandshakeError reports whether err was caused by a failed SSL or SASL
+// handshake or by the cluster rejecting the client.
+func isHandshakeError(err error) bool {
+	var kafkaErr kafka.Error
+	if !errors.As(err, &kafkaErr) {
+		return false
+	}
+
+	switch kafkaErr.Code() {
+	case kafka.ErrAuthentication, kafka.ErrSsl, kafka.ErrSaslAuthenticationFailed,
+		kafka.ErrClusterAuthorizationFailed, kafka.ErrTopicAuthorizationFailed:
+		return true
+	}
+
+	return false
+}
+
+// isUnreachableError reports whether err is how the admin client fails when
+// it cannot connect to the brokers, e.g. because every handshake is rejected.
+func isUnreachableError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var kafkaErr kafka.Error
+	if !errors.As(err, &kafkaErr) {
+		return false
+	}
+
+	switch kafkaErr.Code() {
+	case kafka.ErrTimedOut, kafka.ErrTimedOutQueue, kafka.ErrTransport, kafka.ErrAllBrokersDown:
+		return true
+	}
+
+	return false
+}
+
+// HandshakeError is returned when the cluster could not be reached because the
+// security settings of a connection were rejected.
+type HandshakeError struct {
+	Connection string
+	Security   string
+	Err        error
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("Failed to authenticate connection '%s' using %s, check its security settings: %s",
+		e.Connection, e.Security, e.Err)
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,152 @@
+package djafka
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestSecurityProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		security Security
+		want     kafka.ConfigMap
+		err      string
+	}{
+		{
+			name:     "plaintext",
+			security: Security{},
+			want:     kafka.ConfigMap{"security.protocol": ProtocolPlaintext},
+		},
+		{
+			name:     "plain",
+			security: Security{Protocol: "sasl_plaintext", Mechanism: "plain", Username: "user", Password: "secret"},
+			want: kafka.ConfigMap{
+				"security.protocol": ProtocolSASLPlaintext,
+				"sasl.mechanisms":   MechanismPlain,
+				"sasl.username":     "user",
+				"sasl.password":     "secret",
+			},
+		},
+		{
+			name:     "scram with derived protocol",
+			security: Security{Mechanism: MechanismScramSHA512, Username: "user", Password: "secret", CALocation: "ca.pem"},
+			want: kafka.ConfigMap{
+				"security.protocol": ProtocolSASLSSL,
+				"sasl.mechanisms":   MechanismScramSHA512,
+				"sasl.username":     "user",
+				"sasl.password":     "secret",
+				"ssl.ca.location":   "ca.pem",
+			},
+		},
+		{
+			name: "oauthbearer",
+			security: Security{
+				Mechanism:     MechanismOAuthBearer,
+				TokenEndpoint: "https://idp/token",
+				ClientId:      "djafka",
+				ClientSecret:  "secret",
+				Scope:         "kafka",
+			},
+			want: kafka.ConfigMap{
+				"security.protocol":                   ProtocolSASLSSL,
+				"sasl.mechanisms":                     MechanismOAuthBearer,
+				"sasl.oauthbearer.method":             "oidc",
+				"sasl.oauthbearer.token.endpoint.url": "https://idp/token",
+				"sasl.oauthbearer.client.id":          "djafka",
+				"sasl.oauthbearer.client.secret":      "secret",
+				"sasl.oauthbearer.scope":              "kafka",
+			},
+		},
+		{
+			name:     "mtls",
+			security: Security{CALocation: "ca.pem", CertificateLocation: "client.pem", KeyLocation: "client.key", KeyPassword: "secret"},
+			want: kafka.ConfigMap{
+				"security.protocol":        ProtocolSSL,
+				"ssl.ca.location":          "ca.pem",
+				"ssl.certificate.location": "client.pem",
+				"ssl.key.location":         "client.key",
+				"ssl.key.password":         "secret",
+			},
+		},
+		{
+			name:     "unknown protocol",
+			security: Security{Protocol: "carrier-pigeon"},
+			err:      "unknown security protocol 'carrier-pigeon'",
+		},
+		{
+			name:     "mechanism without sasl",
+			security: Security{Protocol: ProtocolSSL, Mechanism: MechanismPlain},
+			err:      "mechanism 'PLAIN' requires protocol SASL_PLAINTEXT or SASL_SSL",
+		},
+		{
+			name:     "sasl without mechanism",
+			security: Security{Protocol: ProtocolSASLSSL},
+			err:      "protocol SASL_SSL requires a mechanism",
+		},
+		{
+			name:     "unknown mechanism",
+			security: Security{Mechanism: "GSSAPI"},
+			err:      "unknown SASL mechanism 'GSSAPI'",
+		},
+		{
+			name:     "scram without password",
+			security: Security{Mechanism: MechanismScramSHA256, Username: "user"},
+			err:      "mechanism SCRAM-SHA-256 requires a username and a password",
+		},
+		{
+			name:     "oauthbearer without client secret",
+			security: Security{Mechanism: MechanismOAuthBearer, TokenEndpoint: "https://idp/token", ClientId: "djafka"},
+			err:      "requires a token endpoint, a client id and a client secret",
+		},
+		{
+			name:     "certificate without key",
+			security: Security{CertificateLocation: "client.pem"},
+			err:      "client certificates require both a certificate and a key location",
+		},
+		{
+			name:     "certificates without ssl",
+			security: Security{Protocol: ProtocolPlaintext, CALocation: "ca.pem"},
+			err:      "certificates require protocol SSL or SASL_SSL",
+		},
+	}
+
+	for _, test := range tests {
+		props, err := test.security.properties()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(props, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, props)
+		}
+	}
+}
+
+func TestUnreachableError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{kafka.NewError(kafka.ErrTransport, "connection refused", false), true},
+		{fmt.Errorf("Failed to list consumer groups: %w", context.DeadlineExceeded), true},
+		{kafka.NewError(kafka.ErrUnknownTopicOrPart, "unknown topic", false), false},
+		{kafka.NewError(kafka.ErrSaslAuthenticationFailed, "bad password", false), false},
+	}
+
+	for _, test := range tests {
+		if got := isUnreachableError(test.err); got != test.want {
+			t.Errorf("%v: expected %t, got %t", test.err, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package djafka
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SeekMode is where browsing the messages of a topic starts.
+type SeekMode int
+
+const (
+	SeekEarliest SeekMode = iota
+	SeekLatest
+	SeekOffset
+	SeekLastN
+	SeekTimestamp
+)
+
+// StartPosition is the position of every partition of a topic to read from.
+type StartPosition struct {
+	Mode SeekMode
+	// Offset is used for all partitions with SeekOffset, unless Offsets is
+	// given, then only the partitions listed in Offsets are read
+	Offset  int64
+	Offsets map[int32]int64
+	// Count is the number of records per partition with SeekLastN
+	Count     int64
+	Timestamp time.Time
+}
+
+// offset returns the offset of partition with SeekOffset, false if the
+// partition is not read.
+func (p StartPosition) offset(partition int32) (int64, bool) {
+	if p.Offsets == nil {
+		return p.Offset, true
+	}
+	offset, ok := p.Offsets[partition]
+	return offset, ok
+}
+
+// unknownPartition returns a partition of Offsets which the topic with the
+// given partition count does not have.
+func (p StartPosition) unknownPartition(count int) (int32, bool) {
+	for partition := range p.Offsets {
+		if partition < 0 || int(partition) >= count {
+			return partition, true
+		}
+	}
+	return 0, false
+}
+
+func (p StartPosition) String() string {
+	switch p.Mode {
+	case SeekLatest:
+		return "from end"
+	case SeekOffset:
+		if len(p.Offsets) == 0 {
+			return fmt.Sprintf("from offset %d", p.Offset)
+		}
+		partitions := make([]int, 0, len(p.Offsets))
+		for partition := range p.Offsets {
+			partitions = append(partitions, int(partition))
+		}
+		sort.Ints(partitions)
+		offsets := []string{}
+		for _, partition := range partitions {
+			offsets = append(offsets, fmt.Sprintf("%d:%d", partition, p.Offsets[int32(partition)]))
+		}
+		return fmt.Sprintf("from offsets %s", strings.Join(offsets, ","))
+	case SeekLastN:
+		return fmt.Sprintf("last %d per partition", p.Count)
+	case SeekTimestamp:
+		return fmt.Sprintf("since %s", p.Timestamp.Format(timestampLayout))
+	default:
+		return "from beginning"
+	}
+}
+
+// ParseStartPosition parses the input of the seek prompt for mode:
+//   - SeekOffset: an offset for all partitions like "42", or offsets per
+//     partition like "0:42,1:17"
+//   - SeekLastN: the number of records per partition
+//   - SeekTimestamp: a time like "2023-06-01 12:30:00" or "2023-06-01" in
+//     local time, or a duration like "15m" before now
+func ParseStartPosition(mode SeekMode, input string) (StartPosition, error) {
+	input = strings.TrimSpace(input)
+	position := StartPosition{Mode: mode}
+
+	switch mode {
+	case SeekOffset:
+		if !strings.Contains(input, ":") {
+			offset, err := strconv.ParseInt(input, 10, 64)
+			if err != nil || offset < 0 {
+				return position, fmt.Errorf("'%s' is not a valid offset", input)
+			}
+			position.Offset = offset
+			return position, nil
+		}
+
+		position.Offsets = map[int32]int64{}
+		for _, item := range strings.Split(input, ",") {
+			partition, offset, _ := strings.Cut(strings.TrimSpace(item), ":")
+			p, err := strconv.ParseInt(partition, 10, 32)
+			if err != nil || p < 0 {
+				return position, fmt.Errorf("'%s' is not a valid partition", partition)
+			}
+			o, err := strconv.ParseInt(offset, 10, 64)
+			if err != nil || o < 0 {
+				return position, fmt.Errorf("'%s' is not a valid offset", offset)
+			}
+			position.Offsets[int32(p)] = o
+		}
+	case SeekLastN:
+		count, err := strconv.ParseInt(input, 10, 64)
+		if err != nil || count < 1 {
+			return position, fmt.Errorf("'%s' is not a positive number", input)
+		}
+		position.Count = count
+	case SeekTimestamp:
+		if duration, err := time.ParseDuration(input); err == nil {
+			position.Timestamp = time.Now().Add(-duration)
+			return position, nil
+		}
+		if timestamp, ok := parseTime(input); ok {
+			position.Timestamp = timestamp
+			return position, nil
+		}
+		return position, fmt.Errorf("'%s' is neither a time like '%s' nor a duration like '15m'", input, timestampLayout)
+	}
+
+	return position, nil
+}
+
+// parseTime parses a time like "2023-06-01 12:30:00", "2023-06-01 12:30" or
+// "2023-06-01" in local time, or in RFC 3339.
+func parseTime(input string) (time.Time, bool) {
+	for _, layout := range []string{timestampLayout, "2006-01-02 15:04", "2006-01-02", time.RFC3339} {
+		if timestamp, err := time.ParseInLocation(layout, input, time.Local); err == nil {
+			return timestamp, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -2,50 +2,19 @@ package djafka
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 )
 
-type Connection struct {
-	Name            string `json:"name"`
-	BootstrapServer string `json:"bootstrapServer"`
-}
-
-type Config struct {
-	Connections []Connection `json:"connections"`
-}
-
-func (c *Config) FindConnection(name string) (Connection, error) {
-	for _, conn := range c.Connections {
-		if conn.Name == name {
-			return conn, nil
-		}
-	}
-
-	return Connection{}, fmt.Errorf("Failed to find connection for name '%s'.", name)
-}
-
-func ReadConfig() (*Config, error) {
-	file, err := os.Open("config.json")
-	if err != nil {
-		return nil, fmt.Errorf("Failed to read config file: %w", err)
-	}
-	defer file.Close()
-
-	config := Config{}
-	if err := json.NewDecoder(file).Decode(&config); err != nil {
-		return nil, fmt.Errorf("Failed to decode config file: %w", err)
-	}
-
-	return &config, nil
-}
-
 type Consumer struct {
 	GroupId         string
 	ConsumerId      string
@@ -59,11 +28,34 @@ type ConsumerTopicPartition struct {
 	Partition int32
 }
 
+// ErrServiceClosed is returned by calls on a closed Service.
+var ErrServiceClosed = errors.New("Connection was closed.")
+
 type Service struct {
+	conn Connection
+	// resolved is conn with its secrets resolved, for clients created later
+	resolved Connection
 	client   *kafka.AdminClient
-	consumer *kafka.Consumer
-	logger   *log.Logger
-	// producer *kafka.Producer
+	// handle is the producer handle the admin client is derived from, its
+	// events carry the connection errors the admin client does not return
+	handle *kafka.Producer
+	logger *log.Logger
+
+	// handshakeErr is the last handshake error of the handle not yet
+	// explained
+	errMu        sync.Mutex
+	handshakeErr error
+
+	// producer is created by the first Produce, most sessions never produce
+	producerMu sync.Mutex
+	producer   *kafka.Producer
+
+	// mu is read locked by every running request, so the clients are not
+	// closed while in use
+	mu        sync.RWMutex
+	closed    bool
+	done      chan struct{}
+	closeOnce sync.Once
 }
 type Topic struct {
 	Name           string
@@ -76,37 +68,242 @@ type TopicConfig struct {
 }
 
 func NewService(conn Connection, logger *log.Logger) (*Service, error) {
-	client, err := kafka.NewAdminClient(&kafka.ConfigMap{
-		"bootstrap.servers": "localhost",
-	})
+	resolved, err := conn.ResolveSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig, err := resolved.ClientConfig(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// an admin client is a producer handle which never produces, creating
+	// it from one gives access to its error events
+	handle, err := kafka.NewProducer(clientConfig)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to initialise kafka admin client: %w", err)
 	}
 
-	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": "localhost",
-		"group.id":          "testis",
+	client, err := kafka.NewAdminClientFromProducer(handle)
+	if err != nil {
+		handle.Close()
+		return nil, fmt.Errorf("Failed to initialise kafka admin client: %w", err)
+	}
+
+	s := &Service{conn: conn, resolved: resolved, client: client, handle: handle, logger: logger, done: make(chan struct{})}
+	go s.watchErrors()
+
+	return s, nil
+}
+
+// watchErrors keeps the last handshake error of the handle for explain and
+// logs the other errors, until the handle is closed.
+func (s *Service) watchErrors() {
+	for event := range s.handle.Events() {
+		err, ok := event.(kafka.Error)
+		if !ok {
+			continue
+		}
+		if !isHandshakeError(err) {
+			s.logger.Println("Client error of", s.conn.Name, err)
+			continue
+		}
+
+		s.errMu.Lock()
+		s.handshakeErr = err
+		s.errMu.Unlock()
+	}
+}
+
+// producerClient returns the producer, creating it on first use. It must be
+// called with a request acquired, so the service is not closed meanwhile.
+func (s *Service) producerClient() (*kafka.Producer, error) {
+	s.producerMu.Lock()
+	defer s.producerMu.Unlock()
+
+	if s.producer != nil {
+		return s.producer, nil
+	}
+
+	producerConfig, err := s.resolved.ClientConfig(kafka.ConfigMap{
+		// deliveries are given up with the request timeout
+		"message.timeout.ms": int(s.conn.RequestTimeout().Milliseconds()),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to initialise kafka consumer: %w", err)
+		return nil, err
 	}
 
-	// producer, err := kafka.NewProducer(&kafka.ConfigMap{
-	// 	"bootstrap.servers": "localhost",
-	// })
+	producer, err := kafka.NewProducer(producerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialise kafka producer: %w", err)
+	}
 
-	return &Service{client, consumer, logger}, err
+	// delivery reports are sent to the channel passed to Produce, the other
+	// events have to be drained
+	go func() {
+		for event := range producer.Events() {
+			if err, ok := event.(kafka.Error); ok {
+				s.logger.Println("Producer error of", s.conn.Name, err)
+			}
+		}
+	}()
+
+	s.producer = producer
+	return producer, nil
+}
+
+// inspectionGroupPrefix starts the group ids of the consumers used to read
+// messages, which are left out of the consumer groups listed.
+const inspectionGroupPrefix = "djafka-inspect-"
+
+func isInspectionGroup(groupId string) bool {
+	return strings.HasPrefix(groupId, inspectionGroupPrefix)
+}
+
+// consumerErrorBackoff is how long reading messages waits after an error the
+// consumer recovers from.
+const consumerErrorBackoff = time.Second
+
+// inspectionConfig configures a consumer which only reads: it is assigned
+// partitions manually and never commits, so its unique group never shows up
+// in the cluster and never takes partitions from other consumers.
+func inspectionConfig() kafka.ConfigMap {
+	id := make([]byte, 8)
+	// the id only has to be unique, a failed read still leaves a usable id
+	_, _ = rand.Read(id)
+
+	return kafka.ConfigMap{
+		"group.id":                 inspectionGroupPrefix + hex.EncodeToString(id),
+		"enable.auto.commit":       false,
+		"enable.auto.offset.store": false,
+		"auto.offset.reset":        "earliest",
+	}
 }
 
+// Close cancels all running requests and closes the clients once they
+// returned.
 func (s *Service) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.producer != nil {
+		s.producer.Close()
+	}
 	s.client.Close()
+	s.handle.Close()
+	s.logger.Println("Closed service of", s.conn.Name)
 }
 
-func (s *Service) ListTopics() ([]Topic, error) {
-	metaData, err := s.client.GetMetadata(nil, true, 5000)
+// acquire must be called before using the clients. The returned context is
+// limited by the request timeout of the connection and cancelled when the
+// service is closed. The returned release function must be called when done.
+func (s *Service) acquire(ctx context.Context) (context.Context, func(), error) {
+	return s.acquireFor(ctx, s.conn.RequestTimeout())
+}
+
+// acquireStream is acquire for reading records until ctx is done, so the
+// returned context has no timeout. The requests made before reading are
+// limited by the request timeout with timeoutMs.
+func (s *Service) acquireStream(ctx context.Context) (context.Context, func(), error) {
+	return s.acquireFor(ctx, 0)
+}
+
+func (s *Service) acquireFor(ctx context.Context, timeout time.Duration) (context.Context, func(), error) {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil, nil, ErrServiceClosed
+	}
+
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		case <-stop:
+		}
+	}()
+
+	return ctx, func() {
+		close(stop)
+		cancel()
+		s.mu.RUnlock()
+	}, nil
+}
+
+// timeoutMs returns the time left until the deadline of ctx for the client
+// calls which do not accept a context, or the request timeout of the
+// connection if ctx has no deadline.
+func (s *Service) timeoutMs(ctx context.Context) int {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return int(s.conn.RequestTimeout().Milliseconds())
+	}
+
+	remaining := int(time.Until(deadline).Milliseconds())
+	if remaining < 1 {
+		return 1
+	}
+	return remaining
+}
+
+// explain turns errors caused by a rejected SSL or SASL handshake into a
+// HandshakeError. The admin client does not return these failures itself, it
+// times out instead, which is why the handshake error reported by its events
+// explains errors of unreachable brokers.
+func (s *Service) explain(err error) error {
+	cause := err
+	if !isHandshakeError(err) {
+		if !isUnreachableError(err) {
+			return err
+		}
+		cause = s.takeHandshakeError()
+		if cause == nil {
+			return err
+		}
+	}
+
+	return &HandshakeError{s.conn.Name, s.conn.Security.Describe(), cause}
+}
+
+// takeHandshakeError returns the last handshake error reported by the events
+// of the handle, if any, and forgets it. It is forgotten as well once a request
+// reached the brokers, so it never explains later failures.
+func (s *Service) takeHandshakeError() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+
+	err := s.handshakeErr
+	s.handshakeErr = nil
+	return err
+}
+
+func (s *Service) ListTopics(ctx context.Context) ([]Topic, error) {
+	ctx, release, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	metaData, err := s.client.GetMetadata(nil, true, s.timeoutMs(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("Failed to fetch meta data: %w", err)
+		return nil, fmt.Errorf("Failed to fetch meta data: %w", s.explain(err))
 	}
+	s.takeHandshakeError()
 
 	topics := []Topic{}
 	for _, topic := range metaData.Topics {
@@ -120,12 +317,48 @@ func (s *Service) ListTopics() ([]Topic, error) {
 	return topics, nil
 }
 
-func (s *Service) CreateTopic(name string, partitions int, replicationFactor int) (Topic, error) {
+// Health fetches the brokers and the controller of the cluster and measures
+// the round trip time of the metadata request.
+func (s *Service) Health(ctx context.Context) ClusterHealth {
+	ctx, release, err := s.acquire(ctx)
+	if err != nil {
+		return ClusterHealth{Err: err}
+	}
+	defer release()
+
+	start := time.Now()
+	metadata, err := s.client.GetMetadata(nil, false, s.timeoutMs(ctx))
+	if err != nil {
+		return ClusterHealth{Err: s.explain(err)}
+	}
+	latency := time.Since(start)
+	s.takeHandshakeError()
+
+	controller, err := s.client.ControllerID(ctx)
+	if err != nil {
+		return ClusterHealth{Err: s.explain(err)}
+	}
+
+	return ClusterHealth{
+		Reachable:  true,
+		Brokers:    len(metadata.Brokers),
+		Controller: controller,
+		Latency:    latency,
+	}
+}
+
+func (s *Service) CreateTopic(ctx context.Context, name string, partitions int, replicationFactor int) (Topic, error) {
+	ctx, release, err := s.acquire(ctx)
+	if err != nil {
+		return Topic{}, err
+	}
+	defer release()
+
 	topicSpec := kafka.TopicSpecification{Topic: name, NumPartitions: partitions, ReplicationFactor: replicationFactor}
-	res, err := s.client.CreateTopics(context.Background(), []kafka.TopicSpecification{topicSpec})
+	res, err := s.client.CreateTopics(ctx, []kafka.TopicSpecification{topicSpec})
 
 	if err != nil {
-		return Topic{}, fmt.Errorf("Failed to create new topic '%s': %w", name, err)
+		return Topic{}, fmt.Errorf("Failed to create new topic '%s': %w", name, s.explain(err))
 	}
 	for _, r := range res {
 		if r.Error.Code() != kafka.ErrNoError {
@@ -136,11 +369,17 @@ func (s *Service) CreateTopic(name string, partitions int, replicationFactor int
 
 }
 
-func (s *Service) GetTopicConfig(name string) (TopicConfig, error) {
-	cfg, err := s.client.DescribeConfigs(context.Background(), []kafka.ConfigResource{{Type: kafka.ResourceTopic, Name: name, Config: []kafka.ConfigEntry{}}})
+func (s *Service) GetTopicConfig(ctx context.Context, name string) (TopicConfig, error) {
+	ctx, release, err := s.acquire(ctx)
+	if err != nil {
+		return TopicConfig{}, err
+	}
+	defer release()
+
+	cfg, err := s.client.DescribeConfigs(ctx, []kafka.ConfigResource{{Type: kafka.ResourceTopic, Name: name, Config: []kafka.ConfigEntry{}}})
 
 	if err != nil {
-		return TopicConfig{}, fmt.Errorf("Failed to config from topic '%s': %w", name, err)
+		return TopicConfig{}, fmt.Errorf("Failed to config from topic '%s': %w", name, s.explain(err))
 	}
 
 	settings := map[string]string{}
@@ -153,14 +392,23 @@ func (s *Service) GetTopicConfig(name string) (TopicConfig, error) {
 	return TopicConfig{configEntry.Name, settings}, nil
 }
 
-func (s *Service) ListConsumerGroups() ([]string, error) {
-	consumerGroups, err := s.client.ListConsumerGroups(context.Background())
+func (s *Service) ListConsumerGroups(ctx context.Context) ([]string, error) {
+	ctx, release, err := s.acquire(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to list consumer groups: %w", err)
+		return nil, err
+	}
+	defer release()
+
+	consumerGroups, err := s.client.ListConsumerGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list consumer groups: %w", s.explain(err))
 	}
 
 	groupIds := []string{}
 	for _, group := range consumerGroups.Valid {
+		if isInspectionGroup(group.GroupID) {
+			continue
+		}
 		groupIds = append(groupIds, group.GroupID)
 	}
 
@@ -169,10 +417,16 @@ func (s *Service) ListConsumerGroups() ([]string, error) {
 	return groupIds, nil
 }
 
-func (s *Service) ListConsumers(groupIds []string) ([]Consumer, error) {
-	consumerGroups, err := s.client.DescribeConsumerGroups(context.Background(), groupIds)
+func (s *Service) ListConsumers(ctx context.Context, groupIds []string) ([]Consumer, error) {
+	ctx, release, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	consumerGroups, err := s.client.DescribeConsumerGroups(ctx, groupIds)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to describe consumer groups: %w", err)
+		return nil, fmt.Errorf("Failed to describe consumer groups: %w", s.explain(err))
 	}
 
 	consumers := []Consumer{}
@@ -192,9 +446,9 @@ func (s *Service) ListConsumers(groupIds []string) ([]Consumer, error) {
 					Partitions: member.Assignment.TopicPartitions,
 				},
 			}
-			consumerGroupOffsetResult, err := s.client.ListConsumerGroupOffsets(context.Background(), consumerGroupTopicPartitions)
+			consumerGroupOffsetResult, err := s.client.ListConsumerGroupOffsets(ctx, consumerGroupTopicPartitions)
 			if err != nil {
-				return nil, fmt.Errorf("Failed to fetch consumer group offsets: %w", err)
+				return nil, fmt.Errorf("Failed to fetch consumer group offsets: %w", s.explain(err))
 			}
 
 			ctp := []ConsumerTopicPartition{}
@@ -215,59 +469,377 @@ func (s *Service) ListConsumers(groupIds []string) ([]Consumer, error) {
 	return consumers, nil
 }
 
-// func (s *Service) PublishMessage(topic string, key string, message string, channel chan kafka.Event) error {
-// 	kafkaMsg := kafka.Message{
-// 		TopicPartition: kafka.TopicPartition{
-// 			Topic:     &topic,
-// 			Partition: kafka.PartitionAny,
-// 		},
-// 		Key:   []byte(key),
-// 		Value: []byte(message),
-// 	}
-// 	fmt.Println("Publishing message to %s:", topic)
-// 	s.producer.Produce(&kafkaMsg, channel)
-// 	return nil
-// }
+// Produce sends record to its topic and waits for the delivery report. The
+// partition may be kafka.PartitionAny to let the producer choose, the offset is
+// ignored. It returns the record as it was delivered.
+func (s *Service) Produce(ctx context.Context, record Record) (Record, error) {
+	ctx, release, err := s.acquire(ctx)
+	if err != nil {
+		return Record{}, err
+	}
+	defer release()
+
+	producer, err := s.producerClient()
+	if err != nil {
+		return Record{}, err
+	}
+
+	deliveries := make(chan kafka.Event, 1)
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &record.Topic, Partition: record.Partition},
+		Key:            record.Key,
+		Value:          record.Value,
+		Headers:        record.Headers,
+		Timestamp:      record.Timestamp,
+	}
+	if err := producer.Produce(msg, deliveries); err != nil {
+		return Record{}, fmt.Errorf("Failed to produce to topic '%s': %w", record.Topic, s.explain(err))
+	}
+
+	select {
+	case event := <-deliveries:
+		delivered, ok := event.(*kafka.Message)
+		if !ok {
+			return Record{}, fmt.Errorf("Failed to produce to topic '%s': %s", record.Topic, event)
+		}
+		if delivered.TopicPartition.Error != nil {
+			return Record{}, fmt.Errorf("Failed to produce to topic '%s': %w", record.Topic, s.explain(delivered.TopicPartition.Error))
+		}
+		return newRecord(delivered), nil
+	case <-ctx.Done():
+		return Record{}, fmt.Errorf("Failed to produce to topic '%s': %w", record.Topic, ctx.Err())
+	}
+}
+
+// FetchMessages sends the records of topic from start on to records until ctx
+// is done. It reads with a consumer of its own, which is assigned all
+// partitions manually and never commits, so no consumer group is affected.
+func (s *Service) FetchMessages(ctx context.Context, topic string, start StartPosition, records chan<- Record) error {
+	ctx, release, err := s.acquireStream(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	consumer, err := s.newInspectionConsumer(topic)
+	if err != nil {
+		return err
+	}
+	defer s.closeConsumer(consumer, topic)
+
+	partitions, err := s.startOffsets(ctx, consumer, topic, start)
+	if err != nil {
+		return err
+	}
+	if err := consumer.Assign(partitions); err != nil {
+		return fmt.Errorf("Failed to assign partitions of topic '%s': %w", topic, err)
+	}
 
-func (s *Service) FetchMessages(topic string, channel chan string) error {
+	return s.readMessages(ctx, consumer, topic, records, nil)
+}
 
-	s.consumer.SubscribeTopics([]string{topic}, nil)
-	defer s.consumer.Close()
+// ReadRange sends the records of topic from start up to, not including, end to
+// records and returns once all were sent. Only the given partitions are read,
+// or all of them if none are given. An end of SeekLatest is the end of the
+// partitions when ReadRange is called. Like FetchMessages, it never commits.
+func (s *Service) ReadRange(ctx context.Context, topic string, partitions []int32, start StartPosition, end StartPosition, records chan<- Record) error {
+	ctx, release, err := s.acquireStream(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	running := true
+	consumer, err := s.newInspectionConsumer(topic)
+	if err != nil {
+		return err
+	}
+	defer s.closeConsumer(consumer, topic)
 
-	for running {
+	starts, err := s.startOffsets(ctx, consumer, topic, start)
+	if err != nil {
+		return err
+	}
+	if starts, err = s.absoluteOffsets(ctx, consumer, starts); err != nil {
+		return err
+	}
+	endOffsets, err := s.startOffsets(ctx, consumer, topic, end)
+	if err != nil {
+		return err
+	}
+	if endOffsets, err = s.absoluteOffsets(ctx, consumer, endOffsets); err != nil {
+		return err
+	}
+
+	// start and end may leave out partitions, so they are checked against
+	// the metadata
+	metadata, err := s.getTopicMetadata(ctx, topic)
+	if err != nil {
+		return err
+	}
+	selected := map[int32]bool{}
+	for _, partition := range partitions {
+		if partition < 0 || int(partition) >= len(metadata.Partitions) {
+			return fmt.Errorf("Topic '%s' has no partition %d.", topic, partition)
+		}
+		selected[partition] = true
+	}
+	endOf := map[int32]int64{}
+	for _, tp := range endOffsets {
+		endOf[tp.Partition] = int64(tp.Offset)
+	}
+	// ends holds the end of every partition not read completely yet
+	ends := map[int32]int64{}
+	assignment := []kafka.TopicPartition{}
+	for _, tp := range starts {
+		if len(selected) > 0 && !selected[tp.Partition] {
+			continue
+		}
+		if end, ok := endOf[tp.Partition]; ok && int64(tp.Offset) < end {
+			ends[tp.Partition] = end
+			assignment = append(assignment, tp)
+		}
+	}
+	if len(assignment) == 0 {
+		return nil
+	}
+
+	if err := consumer.Assign(assignment); err != nil {
+		return fmt.Errorf("Failed to assign partitions of topic '%s': %w", topic, err)
+	}
+
+	return s.readMessages(ctx, consumer, topic, records, func(msg *kafka.Message) (bool, bool) {
+		if msg != nil {
+			partition, offset := msg.TopicPartition.Partition, int64(msg.TopicPartition.Offset)
+			end, ok := ends[partition]
+			if !ok || offset >= end {
+				return false, len(ends) == 0
+			}
+			if offset+1 >= end {
+				delete(ends, partition)
+			}
+			return true, len(ends) == 0
+		}
+
+		// the last offsets before the end may not be records, e.g. after
+		// compaction or transaction markers, but the position moves past them
+		positions, err := consumer.Position(assignment)
+		if err != nil {
+			s.logger.Printf("Failed to get position on topic '%s': %v\n", topic, err)
+			return false, false
+		}
+		for _, position := range positions {
+			if end, ok := ends[position.Partition]; ok && position.Offset >= 0 && int64(position.Offset) >= end {
+				delete(ends, position.Partition)
+			}
+		}
+		return false, len(ends) == 0
+	})
+}
+
+func (s *Service) newInspectionConsumer(topic string) (*kafka.Consumer, error) {
+	config, err := s.resolved.ClientConfig(inspectionConfig())
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := kafka.NewConsumer(config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialise kafka consumer: %w", err)
+	}
+
+	return consumer, nil
+}
+
+func (s *Service) closeConsumer(consumer *kafka.Consumer, topic string) {
+	if err := consumer.Close(); err != nil {
+		s.logger.Println("Failed to close consumer of topic", topic, err)
+	}
+}
+
+// readMessages sends the records read by consumer to records until ctx is
+// done. Unless next is nil, it is called with every message, or with nil when
+// none arrived for a while, and decides whether the message is sent and
+// whether reading is finished.
+func (s *Service) readMessages(ctx context.Context, consumer *kafka.Consumer, topic string, records chan<- Record, next func(msg *kafka.Message) (send bool, finished bool)) error {
+	for {
 		select {
-		case receivedMsg := <-channel:
-			fmt.Println(receivedMsg)
-			running = false
+		case <-ctx.Done():
+			return nil
+		case <-s.done:
+			return nil
 		default:
 		}
-		msg, err := s.consumer.ReadMessage(time.Second)
-		if err == nil {
-			channel <- msg.String()
-			fmt.Printf("Message on %s: %s\n", msg.TopicPartition, string(msg.Value))
-		} else if !err.(kafka.Error).IsTimeout() {
-			// The client will automatically try to recover from all errors.
-			// Timeout is not considered an error because it is raised by
-			// ReadMessage in absence of messages.
-			fmt.Printf("Consumer error: %v (%v)\n", err, msg)
+
+		msg, err := consumer.ReadMessage(100 * time.Millisecond)
+		if err != nil {
+			kafkaErr, ok := err.(kafka.Error)
+			if ok && kafkaErr.IsTimeout() {
+				// raised by ReadMessage in absence of messages
+				if next != nil {
+					if _, finished := next(nil); finished {
+						return nil
+					}
+				}
+				continue
+			}
+			if ok && kafkaErr.IsFatal() {
+				return fmt.Errorf("Failed to read from topic '%s': %w", topic, err)
+			}
+			// the client will automatically try to recover from all other
+			// errors, which are returned at once, so wait before reading
+			// again instead of spinning
+			s.logger.Printf("Consumer error on topic '%s': %v\n", topic, err)
+			select {
+			case <-time.After(consumerErrorBackoff):
+			case <-ctx.Done():
+				return nil
+			case <-s.done:
+				return nil
+			}
+			continue
+		}
+
+		send, finished := true, false
+		if next != nil {
+			send, finished = next(msg)
+		}
+		if send {
+			select {
+			case records <- newRecord(msg):
+			case <-ctx.Done():
+				return nil
+			case <-s.done:
+				return nil
+			}
+		}
+		if finished {
+			return nil
+		}
+	}
+}
+
+// absoluteOffsets replaces the logical offsets of partitions, the beginning
+// and the end, by the watermarks of the partitions.
+func (s *Service) absoluteOffsets(ctx context.Context, consumer *kafka.Consumer, partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.conn.RequestTimeout())
+	defer cancel()
+
+	resolved := make([]kafka.TopicPartition, len(partitions))
+	for i, tp := range partitions {
+		low, high, err := consumer.QueryWatermarkOffsets(*tp.Topic, tp.Partition, s.timeoutMs(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to query offsets of partition '%d': %w", tp.Partition, s.explain(err))
+		}
+		offset := int64(tp.Offset)
+		switch {
+		case tp.Offset == kafka.OffsetEnd || offset > high:
+			offset = high
+		case tp.Offset == kafka.OffsetBeginning || offset < low:
+			offset = low
 		}
+		resolved[i] = tp
+		resolved[i].Offset = kafka.Offset(offset)
 	}
 
-	return nil
+	return resolved, nil
+}
+
+// startOffsets resolves start to an offset for every partition of topic.
+func (s *Service) startOffsets(ctx context.Context, consumer *kafka.Consumer, topic string, start StartPosition) ([]kafka.TopicPartition, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.conn.RequestTimeout())
+	defer cancel()
+
+	metadata, err := s.getTopicMetadata(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Error.Code() != kafka.ErrNoError {
+		return nil, fmt.Errorf("Failed to get metadata of topic '%s': %w", topic, metadata.Error)
+	}
+	if partition, ok := start.unknownPartition(len(metadata.Partitions)); start.Mode == SeekOffset && ok {
+		return nil, fmt.Errorf("Topic '%s' has no partition %d.", topic, partition)
+	}
+
+	partitions := []kafka.TopicPartition{}
+	for _, partition := range metadata.Partitions {
+		tp := kafka.TopicPartition{Topic: &topic, Partition: partition.ID}
+
+		switch start.Mode {
+		case SeekEarliest:
+			tp.Offset = kafka.OffsetBeginning
+		case SeekLatest:
+			tp.Offset = kafka.OffsetEnd
+		case SeekOffset:
+			offset, ok := start.offset(partition.ID)
+			if !ok {
+				continue
+			}
+			// offsets out of range would be reset to the beginning
+			low, high, err := consumer.QueryWatermarkOffsets(topic, partition.ID, s.timeoutMs(ctx))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to query offsets of partition '%d': %w", partition.ID, s.explain(err))
+			}
+			if offset < low {
+				offset = low
+			} else if offset > high {
+				offset = high
+			}
+			tp.Offset = kafka.Offset(offset)
+		case SeekLastN:
+			low, high, err := consumer.QueryWatermarkOffsets(topic, partition.ID, s.timeoutMs(ctx))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to query offsets of partition '%d': %w", partition.ID, s.explain(err))
+			}
+			offset := high - start.Count
+			if offset < low {
+				offset = low
+			}
+			tp.Offset = kafka.Offset(offset)
+		case SeekTimestamp:
+			tp.Offset = kafka.Offset(start.Timestamp.UnixMilli())
+		}
+
+		partitions = append(partitions, tp)
+	}
+
+	if start.Mode == SeekTimestamp {
+		// partitions without a record since the timestamp are resolved to the
+		// end
+		partitions, err = consumer.OffsetsForTimes(partitions, s.timeoutMs(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to look up offsets for %s: %w", start, s.explain(err))
+		}
+	}
+
+	return partitions, nil
+}
+
+func (s *Service) GetTopicMetadata(ctx context.Context, topic string) (kafka.TopicMetadata, error) {
+	ctx, release, err := s.acquire(ctx)
+	if err != nil {
+		return kafka.TopicMetadata{}, err
+	}
+	defer release()
+
+	return s.getTopicMetadata(ctx, topic)
 }
 
-func (s *Service) GetTopicMetadata(topic string) (kafka.TopicMetadata, error) {
-	result, err := s.client.GetMetadata(&topic, false, 5000)
+func (s *Service) getTopicMetadata(ctx context.Context, topic string) (kafka.TopicMetadata, error) {
+	result, err := s.client.GetMetadata(&topic, false, s.timeoutMs(ctx))
 	if err != nil {
-		return kafka.TopicMetadata{}, fmt.Errorf("Failed to get metadata of topic '%s': %w", topic, err)
+		return kafka.TopicMetadata{}, fmt.Errorf("Failed to get metadata of topic '%s': %w", topic, s.explain(err))
 	}
+	s.takeHandshakeError()
 	return result.Topics[topic], nil
 }
 
-func (s *Service) ResetConsumerOffsets(group string, topic string, offset int64) error {
-	topicMetadata, err := s.GetTopicMetadata(topic)
+func (s *Service) ResetConsumerOffsets(ctx context.Context, group string, topic string, offset int64) error {
+	ctx, release, err := s.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	topicMetadata, err := s.getTopicMetadata(ctx, topic)
 	if err != nil {
 		return err
 	}
@@ -281,14 +853,14 @@ func (s *Service) ResetConsumerOffsets(group string, topic string, offset int64)
 		})
 	}
 	s.logger.Println("ResetConsumerOffsets.partitionArg", partitionArg)
-	result, err := s.client.AlterConsumerGroupOffsets(context.Background(), []kafka.ConsumerGroupTopicPartitions{
+	result, err := s.client.AlterConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{
 		{
 			Group:      group,
 			Partitions: partitionArg,
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("Failed to alter consumer group offset: %w", err)
+		return fmt.Errorf("Failed to alter consumer group offset: %w", s.explain(err))
 	}
 
 	for _, res := range result.ConsumerGroupsTopicPartitions {
@@ -299,6 +871,6 @@ func (s *Service) ResetConsumerOffsets(group string, topic string, offset int64)
 			}
 		}
 	}
-	fmt.Println("ResetConsumerOffsets.AlterConsumerGroupOffsets result", result)
+	s.logger.Println("ResetConsumerOffsets.AlterConsumerGroupOffsets result", result)
 	return nil
 }
@@ -0,0 +1,163 @@
+package djafka
+
+import (
+	"log"
+	"sync"
+)
+
+// ViewState is the part of the UI state which is remembered per cluster and
+// restored when switching back to it.
+type ViewState struct {
+	MenuCursor       int
+	ResultRow        string
+	SelectedTopic    *Topic
+	SelectedConsumer *Consumer
+}
+
+// SessionManager keeps one live backend per connection, so switching between
+// clusters does not have to reconnect. Backends are created on first use.
+type SessionManager struct {
+	mu       sync.Mutex
+	backends map[string]KafkaBackend
+	connect  BackendFactory
+	// registries are the schema registry clients of the connections
+	// configuring one, which cache the schemas they loaded
+	registries map[string]*SchemaRegistry
+	// views are guarded separately, so the UI is not blocked while a
+	// backend is connecting
+	viewsMu sync.Mutex
+	views   map[string]ViewState
+	logger  *log.Logger
+}
+
+func NewSessionManager(logger *log.Logger, connect BackendFactory) *SessionManager {
+	return &SessionManager{
+		backends:   map[string]KafkaBackend{},
+		connect:    connect,
+		registries: map[string]*SchemaRegistry{},
+		views:      map[string]ViewState{},
+		logger:     logger,
+	}
+}
+
+// Backend returns the backend of conn, connecting to the cluster if there is
+// no live backend yet.
+func (s *SessionManager) Backend(conn Connection) (KafkaBackend, error) {
+	s.mu.Lock()
+	backend, ok := s.backends[conn.Name]
+	s.mu.Unlock()
+	if ok {
+		return backend, nil
+	}
+
+	// resolving secrets and connecting may take a while, other clusters
+	// must not wait
+	backend, err := s.connect(conn, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	existing, ok := s.backends[conn.Name]
+	if !ok {
+		s.backends[conn.Name] = backend
+	}
+	s.mu.Unlock()
+	if ok {
+		// another caller connected meanwhile
+		go backend.Close()
+		return existing, nil
+	}
+	s.logger.Println("Connected session", conn.Name)
+
+	return backend, nil
+}
+
+// Connected returns the live backend of the connection with the given name
+// without connecting.
+func (s *SessionManager) Connected(name string) (KafkaBackend, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backend, ok := s.backends[name]
+	return backend, ok
+}
+
+// Registry returns the schema registry client of conn, nil if conn has no
+// schema registry.
+func (s *SessionManager) Registry(conn Connection) (*SchemaRegistry, error) {
+	if conn.SchemaRegistry == nil {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	registry, ok := s.registries[conn.Name]
+	s.mu.Unlock()
+	if ok {
+		return registry, nil
+	}
+
+	resolved, err := conn.ResolveSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if registry, ok := s.registries[conn.Name]; ok {
+		return registry, nil
+	}
+	registry = NewSchemaRegistry(*resolved.SchemaRegistry)
+	s.registries[conn.Name] = registry
+	return registry, nil
+}
+
+// Forget closes the backend of the connection with the given name and drops
+// its view state, e.g. after the connection was edited or deleted.
+func (s *SessionManager) Forget(name string) {
+	s.mu.Lock()
+	backend, ok := s.backends[name]
+	delete(s.backends, name)
+	delete(s.registries, name)
+	s.mu.Unlock()
+
+	s.viewsMu.Lock()
+	delete(s.views, name)
+	s.viewsMu.Unlock()
+
+	if ok {
+		// closing waits for running requests, which must not block the UI
+		go backend.Close()
+	}
+}
+
+// SaveView remembers the view state of the connection with the given name.
+func (s *SessionManager) SaveView(name string, view ViewState) {
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+
+	s.views[name] = view
+}
+
+// View returns the remembered view state of the connection with the given
+// name.
+func (s *SessionManager) View(name string) (ViewState, bool) {
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+
+	view, ok := s.views[name]
+	return view, ok
+}
+
+// Close closes the backends of all connections.
+func (s *SessionManager) Close() {
+	s.mu.Lock()
+	backends := s.backends
+	s.backends = map[string]KafkaBackend{}
+	s.mu.Unlock()
+
+	for _, backend := range backends {
+		backend.Close()
+	}
+}
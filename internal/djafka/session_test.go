@@ -0,0 +1,59 @@
+package djafka
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSessionBackends(t *testing.T) {
+	cluster := NewFakeCluster()
+	connecting, release := make(chan struct{}), make(chan struct{})
+	connect := func(conn Connection, logger *log.Logger) (KafkaBackend, error) {
+		if conn.Name == "slow" {
+			close(connecting)
+			<-release
+		}
+		return cluster.Connect(conn, logger)
+	}
+	sessions := NewSessionManager(log.New(io.Discard, "", 0), connect)
+	defer sessions.Close()
+
+	slow := make(chan KafkaBackend)
+	go func() {
+		backend, err := sessions.Backend(Connection{Name: "slow"})
+		if err != nil {
+			t.Error(err)
+		}
+		slow <- backend
+	}()
+	<-connecting
+
+	done := make(chan error)
+	go func() {
+		_, err := sessions.Backend(Connection{Name: "local"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("Connecting to a slow cluster blocked the others")
+	}
+	if _, ok := sessions.Connected("local"); !ok {
+		t.Error("Expected a session of local")
+	}
+	if _, ok := sessions.Connected("slow"); ok {
+		t.Error("Expected no session of slow while connecting")
+	}
+
+	close(release)
+	backend := <-slow
+	if connected, ok := sessions.Connected("slow"); !ok || connected != backend {
+		t.Error("Expected the session of slow once connected")
+	}
+}
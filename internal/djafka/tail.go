@@ -0,0 +1,149 @@
+package djafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tailTickInterval is how often the records and rates of a tail are updated.
+const tailTickInterval = 500 * time.Millisecond
+
+// recordRing keeps the last records pushed to it, dropping the oldest ones
+// once it is full.
+type recordRing struct {
+	records []Record
+	// start is the index of the oldest record once the ring is full
+	start int
+	size  int
+}
+
+func newRecordRing(size int) recordRing {
+	return recordRing{records: make([]Record, 0, size), size: size}
+}
+
+func (r *recordRing) push(record Record) {
+	if len(r.records) < r.size {
+		r.records = append(r.records, record)
+		return
+	}
+	r.records[r.start] = record
+	r.start = (r.start + 1) % r.size
+}
+
+// all returns a copy of the records, the oldest first.
+func (r *recordRing) all() []Record {
+	all := make([]Record, 0, len(r.records))
+	all = append(all, r.records[r.start:]...)
+	return append(all, r.records[:r.start]...)
+}
+
+// tailStream follows the end of all partitions of a topic. Its records are
+// read as soon as they arrive into a ring of the last ones, so a slow view
+// never holds up the consumer, and are taken from there on every tick.
+type tailStream struct {
+	mu   sync.Mutex
+	ring recordRing
+	// received counts all records and their bytes, kept the records which
+	// matched the filter and were pushed to the ring
+	received int64
+	bytes    int64
+	kept     int64
+	done     chan error
+	stopped  bool
+	err      error
+	cancel   context.CancelFunc
+	// now is the clock rates are computed with
+	now     func() time.Time
+	started time.Time
+}
+
+// tailSnapshot is the state of a tail stream at a tick.
+type tailSnapshot struct {
+	// at is when the snapshot was taken, ticks may arrive late
+	at       time.Time
+	records  []Record
+	received int64
+	bytes    int64
+	kept     int64
+	stopped  bool
+	err      error
+}
+
+// startTailStream follows topic from its end, keeping the last size records
+// matching filter, all if it is nil. Snapshots are timed with now.
+func startTailStream(ctx context.Context, backend KafkaBackend, topic string, size int, filter *Filter, now func() time.Time) *tailStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &tailStream{
+		ring:    newRecordRing(size),
+		done:    make(chan error, 1),
+		cancel:  cancel,
+		now:     now,
+		started: now(),
+	}
+	records := make(chan Record)
+	go func() {
+		s.done <- backend.FetchMessages(ctx, topic, StartPosition{Mode: SeekLatest}, records)
+	}()
+	go func() {
+		for {
+			select {
+			case record := <-records:
+				s.add(record, filter)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *tailStream) add(record Record, filter *Filter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.received++
+	s.bytes += int64(len(record.Key) + len(record.Value))
+	if filter != nil && !filter.Match(record) {
+		return
+	}
+	s.kept++
+	s.ring.push(record)
+}
+
+func (s *tailStream) snapshot() tailSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stopped {
+		select {
+		case err := <-s.done:
+			s.stopped, s.err = true, err
+		default:
+		}
+	}
+	return tailSnapshot{
+		at:       s.now(),
+		records:  s.ring.all(),
+		received: s.received,
+		bytes:    s.bytes,
+		kept:     s.kept,
+		stopped:  s.stopped,
+		err:      s.err,
+	}
+}
+
+// tick returns the records of the stream after tailTickInterval. The schemas
+// of records in the wire format of registry, if not nil, are loaded before.
+func (s *tailStream) tick(ctx context.Context, registry *SchemaRegistry) tea.Cmd {
+	return tea.Tick(tailTickInterval, func(time.Time) tea.Msg {
+		snapshot := s.snapshot()
+		if registry != nil {
+			registry.Prefetch(ctx, snapshot.records)
+		}
+		return TailMsg{s, snapshot}
+	})
+}
@@ -0,0 +1,33 @@
+package djafka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecordRing(t *testing.T) {
+	ring := newRecordRing(3)
+	offsets := func() []int64 {
+		offsets := []int64{}
+		for _, record := range ring.all() {
+			offsets = append(offsets, record.Offset)
+		}
+		return offsets
+	}
+
+	if got := offsets(); len(got) != 0 {
+		t.Errorf("Empty ring returned %v", got)
+	}
+	for offset := int64(0); offset < 2; offset++ {
+		ring.push(Record{Offset: offset})
+	}
+	if got := offsets(); !reflect.DeepEqual(got, []int64{0, 1}) {
+		t.Errorf("Got %v, want [0 1]", got)
+	}
+	for offset := int64(2); offset < 7; offset++ {
+		ring.push(Record{Offset: offset})
+	}
+	if got := offsets(); !reflect.DeepEqual(got, []int64{4, 5, 6}) {
+		t.Errorf("Got %v, want the last three records [4 5 6]", got)
+	}
+}
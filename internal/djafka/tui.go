@@ -1,22 +1,24 @@
 package djafka
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-type DataProvider interface {
-	ListTopics() ([]string, error)
-	ListConsumerGroups() ([]string, error)
-	ListConsumers(groupIds []string) ([]Consumer, error)
-}
-
 type sessionState uint
 
 const (
@@ -27,14 +29,44 @@ const (
 	errorState
 	addTopicState
 	resetOffsetState
+	onboardingState
+	connectionPromptState
+	confirmState
+	messagesState
+	producePromptState
+	bulkProduceState
+	exportState
+	schemaState
 )
 
 var baseStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.NormalBorder()).
 	BorderForeground(lipgloss.Color("240"))
 
+// cursorMode is the mode of the cursors of all inputs. The tests turn blinking
+// off, so views do not depend on when a blink command returns.
+var cursorMode = cursor.CursorBlink
+
+// newTextInput returns a text input with its cursor in cursorMode.
+func newTextInput() textinput.Model {
+	input := textinput.New()
+	input.Cursor.SetMode(cursorMode)
+	return input
+}
+
+// newTextArea returns a text area with its cursor in cursorMode.
+func newTextArea() textarea.Model {
+	area := textarea.New()
+	area.Cursor.SetMode(cursorMode)
+	return area
+}
+
 type model struct {
 	logger            *log.Logger
+	connect           BackendFactory
+	config            *Config
+	configPath        string
+	windowSize        tea.WindowSizeMsg
 	state             sessionState
 	previousState     sessionState
 	errorComponent    ErrorComponent
@@ -42,7 +74,7 @@ type model struct {
 	resultComponent   ResultComponent
 	detailsComponent  DetailsComponent
 	selectionTable    Menu
-	service           *Service
+	backend           KafkaBackend
 	help              HelpComponent
 	infoComponent     InfoComponent
 	startupComponent  StartupComponent
@@ -50,27 +82,63 @@ type model struct {
 	resetOffsetPrompt ResetOffsetPrompt
 	selectedConsumer  *Consumer
 	selectedTopic     *Topic
+	connectionPrompt  ConnectionPrompt
+	confirmComponent  ConfirmComponent
+	messages          MessagesComponent
+	producePrompt     ProducePrompt
+	bulkProducePrompt BulkProducePrompt
+	exportPrompt      ExportPrompt
+	schemaComponent   SchemaComponent
+	healthProbe       *HealthProbe
+	probing           map[string]bool
+	// rewriteWarned are the config files which were not saved as they would
+	// lose their comments, saving to them again rewrites them
+	rewriteWarned    map[string]bool
+	sessions         *SessionManager
+	activeConnection string
+	// configErr is why the config could not be read, it is shown until the
+	// config was fixed and read again
+	configErr error
+	// requests is cancelled when switching to another cluster or quitting
+	requests       context.Context
+	cancelRequests context.CancelFunc
 }
 
 func (m *model) Init() tea.Cmd {
-	config, err := ReadConfig()
-	if err != nil {
-		panic(err)
+	if m.configErr != nil {
+		m.state = errorState
+		m.errorComponent = ErrorComponent{
+			Message: m.configErr.Error(),
+			Hint:    "Fix the config file and press r to read it again, or q to quit.",
+			Width:   m.windowSize.Width,
+			Height:  m.windowSize.Height,
+		}
+		return nil
 	}
 
-	connectionColumns := []table.Column{
-		{Title: ConnectionsLabel, Width: 30},
+	if m.config == nil || len(m.config.Connections) == 0 {
+		m.state = onboardingState
+		m.connectionPrompt = InitialConnectionPrompt(m.logger, "Welcome to djafka! Let's set up your first connection.", Connection{}, "")
+		return m.connectionPrompt.Init()
 	}
 
-	connectionRows := []table.Row{}
-	for _, connection := range config.Connections {
-		connectionRows = append(connectionRows, table.Row{connection.Name})
+	return m.setup()
+}
+
+// setup builds all components for the connections of the config.
+func (m *model) setup() tea.Cmd {
+	config := m.config
+
+	connectionColumns := []table.Column{
+		{Title: ConnectionsLabel, Width: 16},
+		{Title: SourceLabel, Width: 8},
+		{Title: StatusLabel, Width: 24},
 	}
 
 	selectionColumns := []table.Column{
 		{Title: MenuLabel, Width: 30},
 	}
-	selectionRows := []table.Row{{TopicsLabel}, {ConsumerGroupsLabel}, {InfoLabel}}
+	selectionRows := []table.Row{{TopicsLabel}, {ConsumerGroupsLabel}, {SchemasLabel}, {InfoLabel}}
 
 	resultColumns := []table.Column{
 		{Title: ResultLabel, Width: 60},
@@ -83,7 +151,7 @@ func (m *model) Init() tea.Cmd {
 	resultRows := []table.Row{}
 	detailRows := []table.Row{}
 
-	connectionTable := buildTable(connectionColumns, connectionRows)
+	connectionTable := buildTable(connectionColumns, []table.Row{})
 	selectionTable := buildTable(selectionColumns, selectionRows)
 	resultTable := buildTable(resultColumns, resultRows)
 	detailsTable := buildTable(detailColumns, detailRows)
@@ -93,9 +161,9 @@ func (m *model) Init() tea.Cmd {
 	help.FullHelpView(defaultKeys.FullHelp())
 
 	connectionComponent := ConnectionComponent{
-		Model:  connectionTable,
-		config: config,
+		Model: connectionTable,
 	}
+	connectionComponent.SetConfig(config, "")
 
 	menu := Menu{
 		Model: selectionTable,
@@ -126,6 +194,10 @@ func (m *model) Init() tea.Cmd {
 
 	*m = model{
 		logger:            m.logger,
+		connect:           m.connect,
+		config:            m.config,
+		configPath:        m.configPath,
+		windowSize:        m.windowSize,
 		state:             connectionState,
 		previousState:     connectionState,
 		errorComponent:    ErrorComponent{},
@@ -133,7 +205,7 @@ func (m *model) Init() tea.Cmd {
 		resultComponent:   resultComponent,
 		detailsComponent:  detailsComponent,
 		selectionTable:    menu,
-		service:           nil,
+		backend:           nil,
 		help:              helpComponent,
 		infoComponent:     infoComponent,
 		startupComponent:  startupComponent,
@@ -141,9 +213,14 @@ func (m *model) Init() tea.Cmd {
 		resetOffsetPrompt: resetOffsetPrompt,
 		selectedConsumer:  nil,
 		selectedTopic:     nil,
+		probing:           map[string]bool{},
+		rewriteWarned:     map[string]bool{},
+		sessions:          NewSessionManager(m.logger, m.connect),
 	}
+	m.healthProbe = NewHealthProbe(m.logger, m.connect, m.sessions)
+	m.requests, m.cancelRequests = context.WithCancel(context.Background())
 
-	return tea.Batch(changeConnection(config.Connections[0]), cmd)
+	return tea.Batch(changeConnection(config.Connections[0]), cmd, m.probeHealth(), healthTick())
 }
 
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -154,7 +231,85 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	_, isResetOffsetPromptResult := msg.(ResetOffsetMsg)
 	_, isAddTopicCancel := msg.(AddTopicCancel)
 
-	if m.state == errorState {
+	if resizeMsg, isResized := msg.(tea.WindowSizeMsg); isResized {
+		m.windowSize = resizeMsg
+	}
+
+	_, isConfirmCancel := msg.(ConfirmCancelMsg)
+	_, isDeleteConnection := msg.(DeleteConnectionMsg)
+
+	switch msg := msg.(type) {
+	case HealthTickMsg:
+		return m, tea.Batch(m.probeHealth(), healthTick())
+	case HealthCheckedMsg:
+		delete(m.probing, msg.name)
+		m.connectionTable.SetHealth(msg.name, msg.health)
+		return m, nil
+	}
+
+	if m.configErr != nil {
+		return m.updateConfigError(msg)
+	} else if m.state == onboardingState || m.state == connectionPromptState {
+		return m.updateConnectionPrompt(msg)
+	} else if m.state == confirmState && !isConfirmCancel && !isDeleteConnection {
+		m.confirmComponent, cmd = m.confirmComponent.Update(msg)
+		return m, cmd
+	} else if m.state == messagesState {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			if msg.String() == ESC && m.messages.CanClose() {
+				m.messages.Stop()
+				m.restoreState()
+				return m, nil
+			}
+			m.messages, cmd = m.messages.Update(msg)
+			return m, cmd
+		case RecordsMsg, RecordsDoneMsg, SearchTickMsg, TailMsg, tea.WindowSizeMsg:
+			m.messages, cmd = m.messages.Update(msg)
+			cmds = append(cmds, cmd)
+		case TopicSettingsMsg:
+			if err := m.saveTopicSettings(msg.topic, msg.settings); err != nil {
+				m.logger.Println(err)
+				m.messages.SetSettingsError(err)
+			}
+		}
+	} else if m.state == producePromptState {
+		switch msg := msg.(type) {
+		case ProduceCancel:
+			m.restoreState()
+			return m, nil
+		case ProduceSubmitMsg:
+			return m, m.produce(Record(msg))
+		case DeliveredMsg:
+			m.producePrompt.SetDelivery(msg)
+			return m, nil
+		}
+		m.producePrompt, cmd = m.producePrompt.Update(msg)
+		return m, cmd
+	} else if m.state == bulkProduceState {
+		if _, ok := msg.(BulkProduceCancel); ok {
+			m.bulkProducePrompt.Stop()
+			m.restoreState()
+			return m, nil
+		}
+		m.bulkProducePrompt, cmd = m.bulkProducePrompt.Update(msg)
+		return m, cmd
+	} else if m.state == exportState {
+		if _, ok := msg.(ExportCancel); ok {
+			m.exportPrompt.Stop()
+			m.restoreState()
+			return m, nil
+		}
+		m.exportPrompt, cmd = m.exportPrompt.Update(msg)
+		return m, cmd
+	} else if m.state == schemaState {
+		if _, ok := msg.(SchemaClose); ok {
+			m.restoreState()
+			return m, nil
+		}
+		m.schemaComponent, cmd = m.schemaComponent.Update(msg)
+		return m, cmd
+	} else if m.state == errorState {
 		m.errorComponent, cmd = m.errorComponent.Update(msg)
 		cmds = append(cmds, cmd)
 
@@ -193,6 +348,14 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.resultComponent.Focus()
 	case addTopicState:
 	case resetOffsetState:
+	case onboardingState:
+	case connectionPromptState:
+	case confirmState:
+	case messagesState:
+	case producePromptState:
+	case bulkProduceState:
+	case exportState:
+	case schemaState:
 	case detailsState:
 		m.detailsComponent.Focus()
 	default:
@@ -229,6 +392,58 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.state = resetOffsetState
 		case "?":
 			m.help.ShowAll = !m.help.ShowAll
+		case "enter":
+			if subject, ok := m.resultComponent.SelectedSubject(); ok && m.state == resultState {
+				return m, m.openSchema(subject)
+			}
+		case "m":
+			if m.state == resultState && m.resultComponent.IsTopics() && len(m.resultComponent.Rows()) > 0 {
+				return m, m.openMessages(m.resultComponent.SelectedRow()[0])
+			}
+		case "p":
+			if m.state == resultState && m.resultComponent.IsTopics() && len(m.resultComponent.Rows()) > 0 {
+				row := m.resultComponent.SelectedRow()
+				partitions, _ := strconv.Atoi(row[1])
+				m.producePrompt = InitialProducePrompt(m.logger, Topic{row[0], partitions}, m.windowSize.Width)
+				m.previousState = m.state
+				m.state = producePromptState
+				return m, m.producePrompt.Init()
+			}
+		case "f":
+			if m.state == resultState && m.resultComponent.IsTopics() && len(m.resultComponent.Rows()) > 0 {
+				row := m.resultComponent.SelectedRow()
+				partitions, _ := strconv.Atoi(row[1])
+				m.bulkProducePrompt = InitialBulkProducePrompt(m.logger, Topic{row[0], partitions}, m.requests, m.backend)
+				m.previousState = m.state
+				m.state = bulkProduceState
+				return m, m.bulkProducePrompt.Init()
+			}
+		case "s":
+			if m.state == resultState && m.resultComponent.IsTopics() && len(m.resultComponent.Rows()) > 0 {
+				m.exportPrompt = InitialExportPrompt(m.logger, m.resultComponent.SelectedRow()[0], m.requests, m.backend)
+				m.previousState = m.state
+				m.state = exportState
+				return m, m.exportPrompt.Init()
+			}
+		case "n", "e", "c":
+			if m.state == connectionState {
+				m.openConnectionPrompt(msg.String())
+				return m, m.connectionPrompt.Init()
+			}
+		case "x":
+			if m.state == connectionState {
+				conn := m.connectionTable.Selected()
+				message := fmt.Sprintf("Delete connection '%s' from '%s'?", conn.Name, conn.Source.Path)
+				var rewriteErr *RewriteError
+				if errors.As(checkRewrite(conn.Source.Path), &rewriteErr) {
+					message += " " + rewriteErr.Error()
+				}
+				m.confirmComponent = NewConfirmComponent(message, DeleteConnectionMsg(conn))
+				m.confirmComponent, _ = m.confirmComponent.Update(m.windowSize)
+				m.previousState = m.state
+				m.state = confirmState
+				return m, nil
+			}
 		}
 
 	// Resizing
@@ -240,8 +455,33 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Custom messages
 	case ConnectionChangedMsg:
-		cmd := m.changeConnection(Connection(msg))
+		if msg.Name != m.activeConnection {
+			m.cancelRequests()
+			m.requests, m.cancelRequests = context.WithCancel(context.Background())
+		}
+		m.saveView()
+		cmd := m.switchConnection(Connection(msg))
 		cmds = append(cmds, cmd)
+	case ClientConnectedMsg:
+		if msg.name != m.connectionTable.Selected().Name {
+			// the cursor moved on while connecting
+			return m, nil
+		}
+		m.backend = msg.backend
+		m.activeConnection = msg.name
+		m.restoreView()
+	case ConnectFailedMsg:
+		if msg.name == m.connectionTable.Selected().Name {
+			// the topics and groups of the previous cluster must not be
+			// shown under the name of this one
+			m.backend = nil
+			m.activeConnection = ""
+			m.selectedTopic = nil
+			m.selectedConsumer = nil
+			m.resultComponent.SetRows([]table.Row{})
+			m.detailsComponent.SetRows([]table.Row{})
+		}
+		m.triggerErrorState(msg.err)
 	case TopicsSelectedMsg:
 		m.resultComponent.SetRows([]table.Row{})
 		m.resultComponent.SetColumns([]table.Column{
@@ -284,6 +524,30 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		})
 		m.detailsComponent.SetConsumerDetails(Consumer(msg))
 		m.selectedConsumer = &Consumer{msg.GroupId, msg.ConsumerId, msg.State, msg.TopicPartitions}
+	case SchemasSelectedMsg:
+		m.resultComponent.SetRows([]table.Row{})
+		m.resultComponent.SetColumns([]table.Column{
+			{Title: SubjectLabel, Width: 40},
+			{Title: CompatibilityLabel, Width: 20},
+		})
+		m.detailsComponent.SetRows([]table.Row{})
+		registry, err := m.activeRegistry()
+		if err != nil {
+			cmds = append(cmds, sendErrorCmd(err))
+		} else if registry == nil {
+			m.detailsComponent.SetColumns([]table.Column{{Title: SchemasLabel, Width: 60}})
+			m.detailsComponent.SetRows([]table.Row{{"The connection has no schema registry."}})
+		} else {
+			cmds = append(cmds, m.loadSubjects(registry))
+		}
+	case SubjectSelectedMsg:
+		m.detailsComponent.SetRows([]table.Row{})
+		m.detailsComponent.SetColumns([]table.Column{{Title: "Version", Width: 60}})
+		if registry, err := m.activeRegistry(); err == nil && registry != nil {
+			cmds = append(cmds, m.loadSubjectVersions(registry, msg.Name))
+		}
+	case SubjectVersionsLoadedMsg:
+		m.detailsComponent.SetSubjectVersions(msg)
 	case ErrorMsg:
 		m.triggerErrorState(msg)
 	case AddTopicCancel:
@@ -291,19 +555,26 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.restoreState()
 	case AddTopicSubmitMsg:
 		m.logger.Println("Received AddTopicSubmitMsg with values: ", msg.name, msg.paritions, msg.replicationFactor)
-		_, err := m.service.CreateTopic(msg.name, msg.paritions, msg.replicationFactor)
-		if err != nil {
-			cmds = append(cmds, sendErrorCmd(fmt.Errorf("Failed to create topig: %w", err)))
-		}
-		cmd := m.loadTopics()
-		cmds = append(cmds, cmd)
+		cmds = append(cmds, m.createTopic(msg))
 		m.restoreState()
+	case ConfirmCancelMsg:
+		m.restoreState()
+	case DeleteConnectionMsg:
+		m.restoreState()
+		if len(m.config.Connections) == 1 {
+			cmds = append(cmds, sendErrorCmd(fmt.Errorf("Cannot delete the last connection.")))
+			break
+		}
+		if err := DeleteConnection(msg.Source.Path, msg.Name); err != nil {
+			cmds = append(cmds, sendErrorCmd(fmt.Errorf("Failed to delete connection: %w", err)))
+			break
+		}
+		m.logger.Println("Deleted connection", msg.Name)
+		m.forgetSession(msg.Name)
+		cmds = append(cmds, m.reloadConfig(""))
 	case ResetOffsetMsg:
 		m.logger.Println("Received ResetOffsetMsg with: ", msg.consumerGroup, msg.topicName, msg.offset)
-		err := m.service.ResetConsumerOffsets(msg.consumerGroup, msg.topicName, msg.offset)
-		if err != nil {
-			cmds = append(cmds, sendErrorCmd(fmt.Errorf("Failed to reset offset: %w", err)))
-		}
+		cmds = append(cmds, m.resetOffsets(msg))
 		m.restoreState()
 	}
 
@@ -325,6 +596,265 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// openConnectionPrompt shows the prompt to create ("n"), edit ("e") or clone
+// ("c") a connection.
+func (m *model) openConnectionPrompt(mode string) {
+	selected := m.connectionTable.Selected()
+	newSource := NewConfigSource(m.configPath)
+
+	switch mode {
+	case "e":
+		m.connectionPrompt = InitialConnectionPrompt(m.logger, "Edit connection", selected, selected.Name)
+	case "c":
+		clone := selected
+		clone.Name = selected.Name + " (copy)"
+		clone.Source = newSource
+		m.connectionPrompt = InitialConnectionPrompt(m.logger, "Clone connection", clone, "")
+	default:
+		m.connectionPrompt = InitialConnectionPrompt(m.logger, "New connection", Connection{Source: newSource}, "")
+	}
+
+	m.previousState = m.state
+	m.state = connectionPromptState
+}
+
+// openMessages shows the records of topic, streaming new ones until the view
+// is closed.
+func (m *model) openMessages(topic string) tea.Cmd {
+	conn := Connection{}
+	var registry *SchemaRegistry
+	var registryErr error
+	if found, err := m.config.FindConnection(m.activeConnection); err == nil {
+		conn = found
+		registry, registryErr = m.sessions.Registry(conn)
+	}
+	m.messages = NewMessagesComponent(topic, m.windowSize, conn, registry)
+	if registryErr != nil {
+		m.logger.Println(registryErr)
+		m.messages.SetSettingsError(registryErr)
+	}
+	m.previousState = m.state
+	m.state = messagesState
+
+	return m.messages.Start(m.requests, m.backend, StartPosition{Mode: SeekEarliest})
+}
+
+// activeRegistry returns the schema registry of the active connection, nil if
+// it has none.
+func (m *model) activeRegistry() (*SchemaRegistry, error) {
+	conn, err := m.config.FindConnection(m.activeConnection)
+	if err != nil {
+		return nil, err
+	}
+	return m.sessions.Registry(conn)
+}
+
+// openSchema shows the versions of the schema of subject.
+func (m *model) openSchema(subject Subject) tea.Cmd {
+	registry, err := m.activeRegistry()
+	if err != nil {
+		return sendErrorCmd(fmt.Errorf("Failed to open schema registry: %w", err))
+	}
+	if registry == nil {
+		return sendErrorCmd(fmt.Errorf("The connection '%s' has no schema registry.", m.activeConnection))
+	}
+	m.schemaComponent = NewSchemaComponent(m.requests, registry, subject, m.windowSize)
+	m.previousState = m.state
+	m.state = schemaState
+
+	return m.schemaComponent.Init()
+}
+
+// saveTopicSettings remembers the settings of a topic of the active
+// connection in the file the connection came from.
+func (m *model) saveTopicSettings(topic string, settings TopicSettings) error {
+	for i := range m.config.Connections {
+		conn := &m.config.Connections[i]
+		if conn.Name != m.activeConnection {
+			continue
+		}
+		if conn.Topics == nil {
+			conn.Topics = map[string]TopicSettings{}
+		}
+		conn.Topics[topic] = settings
+		err := SaveTopicSettings(conn.Source.Path, conn.Name, topic, settings, m.rewriteWarned[conn.Source.Path])
+		var rewriteErr *RewriteError
+		if errors.As(err, &rewriteErr) {
+			m.rewriteWarned[rewriteErr.Path] = true
+			return fmt.Errorf("%w Press ctrl+s to save anyway.", err)
+		}
+		return err
+	}
+
+	return fmt.Errorf("Failed to find connection '%s'.", m.activeConnection)
+}
+
+func (m *model) produce(record Record) tea.Cmd {
+	backend, ctx := m.backend, m.requests
+	return func() tea.Msg {
+		delivered, err := backend.Produce(ctx, record)
+		return DeliveredMsg{delivered, err}
+	}
+}
+
+// updateConfigError handles the error view shown when the config could not be
+// read, which reads it again once the user fixed it.
+func (m *model) updateConfigError(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.errorComponent, _ = m.errorComponent.Update(msg)
+
+	keyMsg, isKeyMsg := msg.(tea.KeyMsg)
+	if !isKeyMsg {
+		return m, nil
+	}
+	switch keyMsg.String() {
+	case QUIT, CANCEL:
+		return m, tea.Quit
+	case "r":
+		config, err := ReadConfig(m.configPath)
+		if errors.Is(err, ErrNoConfig) {
+			config, err = nil, nil
+		}
+		if err != nil {
+			m.errorComponent.Message = err.Error()
+			return m, nil
+		}
+		m.logger.Println("Read fixed config")
+
+		m.config, m.configErr = config, nil
+		windowSize := m.windowSize
+		return m, tea.Batch(m.Init(), func() tea.Msg { return windowSize })
+	}
+
+	return m, nil
+}
+
+// updateConnectionPrompt handles the prompt to enter a connection, either on
+// the first run without any config or when adding or editing connections. The
+// entered connection is checked before it is saved, unless skipped.
+func (m *model) updateConnectionPrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ConnectionPromptCancel:
+		if m.state == onboardingState {
+			return m, tea.Quit
+		}
+		m.restoreState()
+		return m, nil
+	case ConnectionSubmitMsg:
+		if msg.skipCheck {
+			return m, m.saveConnection(msg.conn)
+		}
+		m.connectionPrompt.SetStatus("Connecting to " + strings.Join(msg.conn.BootstrapServers, ",") + " ...")
+		return m, checkConnection(m.connect, msg.conn, m.logger)
+	case ConnectionCheckedMsg:
+		if msg.err != nil {
+			m.connectionPrompt.SetStatus(fmt.Sprintf("%s\n\nPress enter to try again or ctrl+s to save anyway.", msg.err))
+			return m, nil
+		}
+		return m, m.saveConnection(msg.conn)
+	}
+
+	var cmd tea.Cmd
+	m.connectionPrompt, cmd = m.connectionPrompt.Update(msg)
+	return m, cmd
+}
+
+// saveConnection writes the connection entered in the prompt to its config
+// file and shows it in the connections pane.
+func (m *model) saveConnection(conn Connection) tea.Cmd {
+	if m.state == onboardingState {
+		return m.createConfig(conn)
+	}
+
+	previous := m.connectionPrompt.Previous()
+	if _, err := m.config.FindConnection(conn.Name); err == nil && conn.Name != previous {
+		m.connectionPrompt.SetStatus(fmt.Sprintf("A connection named '%s' already exists.", conn.Name))
+		return nil
+	}
+
+	err := SaveConnection(conn.Source.Path, previous, conn, m.rewriteWarned[conn.Source.Path])
+	var rewriteErr *RewriteError
+	if errors.As(err, &rewriteErr) {
+		m.rewriteWarned[rewriteErr.Path] = true
+		m.connectionPrompt.SetStatus(err.Error() + "\n\nPress ctrl+s to save anyway.")
+		return nil
+	} else if err != nil {
+		m.connectionPrompt.SetStatus(err.Error())
+		return nil
+	}
+	if previous != "" {
+		// a new connection has no session yet
+		m.forgetSession(previous)
+	}
+	m.logger.Println("Saved connection", conn.Name, "to", conn.Source.Path)
+
+	m.restoreState()
+	return m.reloadConfig(conn.Name)
+}
+
+// reloadConfig reads the config files again after they were changed and
+// connects to the connection with the given name, or the first one.
+func (m *model) reloadConfig(selected string) tea.Cmd {
+	config, err := ReadConfig(m.configPath)
+	if err != nil {
+		return sendErrorCmd(err)
+	}
+
+	m.config = config
+	m.connectionTable.SetConfig(config, selected)
+	m.healthProbe.Reset()
+
+	return tea.Batch(changeConnection(m.connectionTable.Selected()), m.probeHealth())
+}
+
+// probeHealth checks the clusters of all connections in the background,
+// skipping those with a probe still running.
+func (m *model) probeHealth() tea.Cmd {
+	cmds := []tea.Cmd{}
+	for _, conn := range m.config.Connections {
+		if m.probing[conn.Name] {
+			continue
+		}
+		m.probing[conn.Name] = true
+
+		conn := conn
+		probe := m.healthProbe
+		cmds = append(cmds, func() tea.Msg {
+			return HealthCheckedMsg{conn.Name, probe.Probe(conn)}
+		})
+	}
+
+	return tea.Batch(cmds...)
+}
+
+func healthTick() tea.Cmd {
+	return tea.Tick(healthInterval, func(time.Time) tea.Msg {
+		return HealthTickMsg{}
+	})
+}
+
+// createConfig writes a new config file containing conn and shows the
+// regular views for it.
+func (m *model) createConfig(conn Connection) tea.Cmd {
+	source := NewConfigSource(m.configPath)
+	conn.Source = source
+	config := &Config{Connections: []Connection{conn}}
+	if err := WriteConfig(source.Path, config); err != nil {
+		m.connectionPrompt.SetStatus(err.Error())
+		return nil
+	}
+	m.logger.Println("Created config file", source.Path)
+
+	m.config = config
+	windowSize := m.windowSize
+	return tea.Batch(m.setup(), func() tea.Msg { return windowSize })
+}
+
+func checkConnection(connect BackendFactory, conn Connection, logger *log.Logger) tea.Cmd {
+	return func() tea.Msg {
+		return ConnectionCheckedMsg{conn, CheckConnection(context.Background(), connect, conn, logger)}
+	}
+}
+
 func (m *model) triggerErrorState(err error) {
 	m.previousState = m.state
 	m.state = errorState
@@ -337,28 +867,69 @@ func (m *model) restoreState() {
 	m.addTopicPrompt = InitialAddTopicPrompt(m.logger) //reset prompt
 }
 
-func (m *model) changeConnection(conn Connection) tea.Cmd {
+// switchConnection connects to the cluster of conn in the background, reusing
+// its session if there is one.
+func (m *model) switchConnection(conn Connection) tea.Cmd {
+	sessions := m.sessions
 	return func() tea.Msg {
-		if m.service != nil {
-			m.service.Close()
-		}
-
-		service, err := NewService(conn, m.logger)
+		backend, err := sessions.Backend(conn)
 		if err != nil {
-			return ErrorMsg(fmt.Errorf("Failed to re-create service: %w", err))
+			return ConnectFailedMsg{conn.Name, fmt.Errorf("Failed to connect to '%s': %w", conn.Name, err)}
 		}
 
-		m.service = service
+		return ClientConnectedMsg{conn.Name, backend}
+	}
+}
+
+// forgetSession closes the connection with the given name after its settings
+// changed.
+func (m *model) forgetSession(name string) {
+	m.sessions.Forget(name)
+	if m.activeConnection == name {
+		m.activeConnection = ""
+	}
+}
+
+// saveView remembers the view of the active cluster before switching to
+// another one.
+func (m *model) saveView() {
+	if m.activeConnection == "" {
+		return
+	}
+
+	view := ViewState{
+		MenuCursor:       m.selectionTable.Cursor(),
+		SelectedTopic:    m.selectedTopic,
+		SelectedConsumer: m.selectedConsumer,
+	}
+	if len(m.resultComponent.Rows()) > 0 {
+		view.ResultRow = m.resultComponent.SelectedRow()[0]
+	}
 
-		return ClientConnectedMsg{}
+	m.sessions.SaveView(m.activeConnection, view)
+}
+
+// restoreView shows the view of the active cluster as it was left.
+func (m *model) restoreView() {
+	view, ok := m.sessions.View(m.activeConnection)
+	if !ok {
+		m.selectedTopic = nil
+		m.selectedConsumer = nil
+		return
 	}
+
+	m.selectionTable.SetCursor(view.MenuCursor)
+	m.resultComponent.RestoreOnLoad(view.ResultRow)
+	m.selectedTopic = view.SelectedTopic
+	m.selectedConsumer = view.SelectedConsumer
 }
 
 func (m *model) loadTopics() tea.Cmd {
+	backend, ctx := m.backend, m.requests
 	return func() tea.Msg {
-		topics, err := m.service.ListTopics()
+		topics, err := backend.ListTopics(ctx)
 		if err != nil {
-			return ErrorMsg(err)
+			return requestError(err)
 		}
 
 		return TopicsLoadedMsg(topics)
@@ -366,31 +937,102 @@ func (m *model) loadTopics() tea.Cmd {
 }
 
 func (m *model) loadTopicSettings(name string) tea.Cmd {
+	backend, ctx := m.backend, m.requests
 	return func() tea.Msg {
-		config, err := m.service.GetTopicConfig(name)
+		config, err := backend.GetTopicConfig(ctx, name)
 		if err != nil {
-			return ErrorMsg(err)
+			return requestError(err)
 		}
 
 		return TopicSettingsLoadedMsg(config)
 	}
 }
 
+func (m *model) loadSubjects(registry *SchemaRegistry) tea.Cmd {
+	ctx := m.requests
+	return func() tea.Msg {
+		subjects, err := registry.Subjects(ctx)
+		if err != nil {
+			return requestError(err)
+		}
+
+		return SubjectsLoadedMsg(subjects)
+	}
+}
+
+func (m *model) loadSubjectVersions(registry *SchemaRegistry, subject string) tea.Cmd {
+	ctx := m.requests
+	return func() tea.Msg {
+		// the schemas are loaded once a version is opened
+		versions, err := registry.Versions(ctx, subject)
+		if err != nil {
+			return requestError(err)
+		}
+
+		return SubjectVersionsLoadedMsg(versions)
+	}
+}
+
 func (m *model) loadConsumers() tea.Cmd {
+	backend, ctx := m.backend, m.requests
 	return func() tea.Msg {
-		consumerGroups, err := m.service.ListConsumerGroups()
+		consumerGroups, err := backend.ListConsumerGroups(ctx)
 		if err != nil {
-			return ErrorMsg(err)
+			return requestError(err)
 		}
-		consumers, err := m.service.ListConsumers(consumerGroups)
+		consumers, err := backend.ListConsumers(ctx, consumerGroups)
 		if err != nil {
-			return ErrorMsg(err)
+			return requestError(err)
 		}
 
 		return ConsumersLoadedMsg(consumers)
 	}
 }
 
+// createTopic creates the topic of msg and loads the topics again.
+func (m *model) createTopic(msg AddTopicSubmitMsg) tea.Cmd {
+	backend, ctx := m.backend, m.requests
+	if backend == nil {
+		return sendErrorCmd(errNotConnected)
+	}
+	loadTopics := m.loadTopics()
+	return func() tea.Msg {
+		if _, err := backend.CreateTopic(ctx, msg.name, msg.paritions, msg.replicationFactor); err != nil {
+			return requestError(fmt.Errorf("Failed to create topic: %w", err))
+		}
+
+		return loadTopics()
+	}
+}
+
+func (m *model) resetOffsets(msg ResetOffsetMsg) tea.Cmd {
+	backend, ctx := m.backend, m.requests
+	if backend == nil {
+		return sendErrorCmd(errNotConnected)
+	}
+	return func() tea.Msg {
+		if err := backend.ResetConsumerOffsets(ctx, msg.consumerGroup, msg.topicName, msg.offset); err != nil {
+			return requestError(fmt.Errorf("Failed to reset offset: %w", err))
+		}
+
+		return nil
+	}
+}
+
+// errNotConnected is reported for requests made before the first connection
+// to a cluster succeeded.
+var errNotConnected = errors.New("Not connected to a cluster yet.")
+
+// requestError reports err, unless the request was cancelled on purpose by
+// switching to another cluster.
+func requestError(err error) tea.Msg {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrServiceClosed) {
+		return nil
+	}
+
+	return ErrorMsg(err)
+}
+
 func sendErrorCmd(err error) tea.Cmd {
 	return func() tea.Msg {
 		return ErrorMsg(err)
@@ -404,6 +1046,14 @@ func reset() tea.Cmd {
 }
 
 func (m *model) View() string {
+	if m.configErr != nil {
+		return m.errorComponent.View()
+	}
+
+	if m.state == onboardingState {
+		return m.connectionPrompt.View()
+	}
+
 	if !m.startupComponent.Initialized() {
 		return m.startupComponent.View()
 	}
@@ -414,6 +1064,20 @@ func (m *model) View() string {
 		return m.addTopicPrompt.View()
 	} else if m.state == resetOffsetState {
 		return m.resetOffsetPrompt.View()
+	} else if m.state == connectionPromptState {
+		return m.connectionPrompt.View()
+	} else if m.state == confirmState {
+		return m.confirmComponent.View()
+	} else if m.state == messagesState {
+		return m.messages.View()
+	} else if m.state == producePromptState {
+		return m.producePrompt.View()
+	} else if m.state == bulkProduceState {
+		return m.bulkProducePrompt.View()
+	} else if m.state == exportState {
+		return m.exportPrompt.View()
+	} else if m.state == schemaState {
+		return m.schemaComponent.View()
 	}
 
 	connectionBorderStyle := defocusTable(&m.connectionTable.Model)
@@ -449,6 +1113,18 @@ func (m *model) View() string {
 	return lipgloss.JoinHorizontal(lipgloss.Top, menuPane, resultPane)
 }
 
+// shutdown cancels all running requests and closes every client.
+func (m *model) shutdown() {
+	if m.sessions == nil {
+		// quit during onboarding
+		return
+	}
+
+	m.cancelRequests()
+	m.sessions.Close()
+	m.healthProbe.Close()
+}
+
 func makeFocused(s lipgloss.Style) lipgloss.Style {
 	return s.BorderForeground(lipgloss.Color("69"))
 }
@@ -521,7 +1197,9 @@ func buildHelp() help.Model {
 	return h
 }
 
-func Run() {
+// Run starts the TUI. configPath may be empty to look up the config file in
+// the default locations.
+func Run(configPath string) {
 	f, err := tea.LogToFile("debug.log", "debug")
 	if err != nil {
 		fmt.Println("fatal:", err)
@@ -531,7 +1209,18 @@ func Run() {
 	logger := log.Default()
 	logger.SetOutput(f)
 
-	if _, err := tea.NewProgram(&model{logger: logger}, tea.WithAltScreen()).Run(); err != nil {
+	config, err := ReadConfig(configPath)
+	if errors.Is(err, ErrNoConfig) {
+		logger.Println("Starting onboarding:", err)
+		err = nil
+	} else if err != nil {
+		logger.Println(err)
+	}
+
+	m := &model{logger: logger, connect: ConnectService, config: config, configPath: configPath, configErr: err}
+	_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+	m.shutdown()
+	if err != nil {
 		fmt.Println("Error running program:", err)
 		os.Exit(1)
 	}
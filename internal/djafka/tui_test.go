@@ -0,0 +1,938 @@
+package djafka
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// cmdTimeout is how long the harness waits for a command. Commands which take
+// longer, like ticks, are dropped so views are stable.
+const cmdTimeout = 100 * time.Millisecond
+
+// testdata is the absolute path of the golden files, tests may change the
+// working directory.
+var testdata string
+
+// TestMain renders with a fixed color profile, so focus and selection styles
+// are part of the golden files, and with cursors which do not blink.
+func TestMain(m *testing.M) {
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	cursorMode = cursor.CursorStatic
+	time.Local = time.UTC
+
+	var err error
+	if testdata, err = filepath.Abs("testdata"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// harness drives a model like tea.Program does, but synchronously and without
+// a terminal.
+type harness struct {
+	t       *testing.T
+	model   *model
+	cluster *FakeCluster
+	quit    bool
+
+	// pending are the results of commands which did not finish in time
+	mu      sync.Mutex
+	pending []chan tea.Msg
+}
+
+func testConfig() *Config {
+	source := ConfigSource{Kind: SourceUser, Path: "config.json"}
+	return &Config{
+		Version: CurrentConfigVersion,
+		Connections: []Connection{
+			{Name: "local", BootstrapServers: []string{"localhost:9092"}, Source: source},
+			{Name: "staging", BootstrapServers: []string{"staging:9092"}, Source: source},
+		},
+	}
+}
+
+func testCluster(t *testing.T) *FakeCluster {
+	cluster := NewFakeCluster()
+	cluster.SetClock(func() time.Time { return testTime })
+	for _, topic := range []struct {
+		name       string
+		partitions int
+	}{{"orders", 3}, {"payments", 1}} {
+		if err := cluster.AddTopic(topic.name, topic.partitions, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cluster.AddConsumerGroup("billing", "Stable", FakeMember{"billing-1", []string{"orders"}})
+	cluster.CommitOffset("billing", "orders", 0, 42)
+	cluster.AddConsumerGroup("audit", "Empty")
+
+	produce(t, cluster, 0, "order-1", `{"id":1,"status":"created"}`, kafka.Header{Key: "source", Value: []byte("shop")})
+
+	return cluster
+}
+
+// testTime is the timestamp of all test records.
+var testTime = time.Date(2023, 6, 1, 12, 30, 0, 0, time.UTC)
+
+func produce(t *testing.T, cluster *FakeCluster, partition int32, key string, value string, headers ...kafka.Header) {
+	produceAt(t, cluster, testTime, partition, key, value, headers...)
+}
+
+func produceAt(t *testing.T, cluster *FakeCluster, timestamp time.Time, partition int32, key string, value string, headers ...kafka.Header) {
+	topic := "orders"
+	_, err := cluster.Produce(kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition},
+		Key:            []byte(key),
+		Value:          []byte(value),
+		Headers:        headers,
+		Timestamp:      timestamp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newHarness starts a model on config, which is connected to cluster, and
+// skips the startup animation.
+func newHarness(t *testing.T, config *Config, cluster *FakeCluster) *harness {
+	return startHarness(t, cluster, &model{
+		logger:     log.New(io.Discard, "", 0),
+		connect:    cluster.Connect,
+		config:     config,
+		configPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+}
+
+// startHarness starts m, which is connected to cluster.
+func startHarness(t *testing.T, cluster *FakeCluster, m *model) *harness {
+	h := &harness{t: t, cluster: cluster, model: m}
+	t.Cleanup(h.model.shutdown)
+
+	cmd := h.model.Init()
+	h.model.startupComponent.SetPercent(1)
+	h.run(cmd, 0)
+	h.send(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	return h
+}
+
+// send passes msgs to the model one after another, running the resulting
+// commands until no more messages arrive.
+func (h *harness) send(msgs ...tea.Msg) {
+	for _, msg := range msgs {
+		h.update(msg, 0)
+	}
+}
+
+// press sends key presses, e.g. "tab", "down" or "ctrl+t".
+func (h *harness) press(keys ...string) {
+	for _, k := range keys {
+		h.send(keyPress(k))
+	}
+}
+
+// typeText sends text as a single key press, like pasting it.
+func (h *harness) typeText(text string) {
+	h.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+}
+
+func (h *harness) update(msg tea.Msg, depth int) {
+	if msg == tea.Quit() {
+		h.quit = true
+		return
+	}
+
+	_, cmd := h.model.Update(msg)
+	h.run(cmd, depth)
+}
+
+func (h *harness) run(cmd tea.Cmd, depth int) {
+	if cmd == nil {
+		return
+	}
+	if depth > 20 {
+		h.t.Fatal("Commands did not settle")
+	}
+
+	for _, msg := range h.execute(cmd, time.Now().Add(cmdTimeout)) {
+		h.update(msg, depth+1)
+	}
+}
+
+// execute runs cmd and the commands of batches concurrently and returns their
+// messages in the order of the commands.
+func (h *harness) execute(cmd tea.Cmd, deadline time.Time) []tea.Msg {
+	result := make(chan tea.Msg, 1)
+	go func() { result <- cmd() }()
+
+	select {
+	case msg := <-result:
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			return h.executeAll(batch, deadline)
+		}
+		if msg == nil {
+			return nil
+		}
+		return []tea.Msg{msg}
+	case <-time.After(time.Until(deadline)):
+		h.mu.Lock()
+		h.pending = append(h.pending, result)
+		h.mu.Unlock()
+		return nil
+	}
+}
+
+// await waits for a pending command to return a message of the same type as
+// example, e.g. the next records of a stream, and passes it to the model.
+// Other pending messages are dropped.
+func (h *harness) await(example tea.Msg) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		h.mu.Lock()
+		var found tea.Msg
+		pending := h.pending[:0]
+		for _, result := range h.pending {
+			select {
+			case msg := <-result:
+				if found == nil && reflect.TypeOf(msg) == reflect.TypeOf(example) {
+					found = msg
+				}
+			default:
+				pending = append(pending, result)
+			}
+		}
+		h.pending = pending
+		h.mu.Unlock()
+
+		if found != nil {
+			h.send(found)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	h.t.Fatalf("No %T arrived", example)
+}
+
+func (h *harness) executeAll(cmds []tea.Cmd, deadline time.Time) []tea.Msg {
+	results := make([][]tea.Msg, len(cmds))
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, cmd tea.Cmd) {
+			defer wg.Done()
+			results[i] = h.execute(cmd, deadline)
+		}(i, cmd)
+	}
+	wg.Wait()
+
+	msgs := []tea.Msg{}
+	for _, result := range results {
+		msgs = append(msgs, result...)
+	}
+	return msgs
+}
+
+func keyPress(k string) tea.KeyMsg {
+	types := map[string]tea.KeyType{
+		"enter":     tea.KeyEnter,
+		"tab":       tea.KeyTab,
+		"shift+tab": tea.KeyShiftTab,
+		"esc":       tea.KeyEsc,
+		"up":        tea.KeyUp,
+		"down":      tea.KeyDown,
+		"backspace": tea.KeyBackspace,
+		"ctrl+c":    tea.KeyCtrlC,
+		"ctrl+o":    tea.KeyCtrlO,
+		"ctrl+s":    tea.KeyCtrlS,
+		"ctrl+t":    tea.KeyCtrlT,
+		"ctrl+u":    tea.KeyCtrlU,
+	}
+	if t, ok := types[k]; ok {
+		return tea.KeyMsg{Type: t}
+	}
+
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+// golden compares the current view to testdata/<name>.golden, or writes it
+// when running with -update.
+func (h *harness) golden(name string) {
+	h.t.Helper()
+
+	path := filepath.Join(testdata, name+".golden")
+	view := h.model.View()
+	if *update {
+		if err := os.MkdirAll(testdata, 0755); err != nil {
+			h.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(view), 0644); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		h.t.Fatalf("Failed to read golden file, run with -update to create it: %s", err)
+	}
+	if view != string(expected) {
+		h.t.Errorf("View does not match testdata/%s.golden, run with -update to accept it:\n%s", name, diff(string(expected), view))
+	}
+}
+
+// diff lists the lines which differ between expected and actual.
+func diff(expected string, actual string) string {
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+
+	var b strings.Builder
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var e, a string
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if e != a {
+			b.WriteString("- " + e + "\n+ " + a + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestOnboarding(t *testing.T) {
+	h := newHarness(t, nil, NewFakeCluster())
+	h.golden("onboarding")
+
+	h.typeText("local")
+	h.press("enter")
+	h.golden("onboarding_missing_servers")
+
+	h.press("esc")
+	if !h.quit {
+		t.Error("Cancelling the onboarding should quit")
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	path := "config.json"
+	if err := os.WriteFile(path, []byte(`{"connections":[{"name":"local","timeoutMs":-1}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadConfig(path)
+	if err == nil {
+		t.Fatal("Expected the config to be invalid")
+	}
+	cluster := testCluster(t)
+	h := startHarness(t, cluster, &model{
+		logger:     log.New(io.Discard, "", 0),
+		connect:    cluster.Connect,
+		configPath: path,
+		configErr:  err,
+	})
+	h.golden("config_error")
+
+	h.press("enter")
+	if h.quit || h.model.configErr == nil {
+		t.Fatal("The config error should stay until the config was fixed")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"connections":[{"name":"local","bootstrapServers":["localhost:9092"]}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h.press("r")
+	h.model.startupComponent.SetPercent(1)
+	h.golden("config_fixed")
+}
+
+func TestStartup(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+	h.golden("startup")
+}
+
+func TestNavigation(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("tab")
+	h.golden("menu_focused")
+
+	h.press("tab")
+	h.golden("topics_focused")
+
+	h.press("down")
+	h.golden("topic_selected")
+
+	h.press("tab")
+	h.golden("details_focused")
+
+	h.press("tab")
+	h.golden("connections_focused")
+}
+
+func TestConsumerGroups(t *testing.T) {
+	cluster := testCluster(t)
+	// the groups of consumers reading messages are never listed
+	cluster.AddConsumerGroup(inspectionGroupPrefix+"0123456789abcdef", "Empty")
+	h := newHarness(t, testConfig(), cluster)
+
+	h.press("tab", "down")
+	h.golden("consumers")
+
+	h.send(ConsumerSelectedMsg{"billing", "billing-1", "Stable", []ConsumerTopicPartition{{"orders", "42", 0}}})
+	h.golden("consumer_selected")
+}
+
+func TestHelp(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("?")
+	h.golden("help")
+}
+
+func TestAddTopic(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("tab", "ctrl+t")
+	h.golden("add_topic_prompt")
+
+	h.typeText("invoices")
+	h.press("tab")
+	h.typeText("2")
+	h.press("tab")
+	h.typeText("1")
+	h.press("enter")
+	h.golden("add_topic_submitted")
+}
+
+func TestAddTopicCancel(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("tab", "ctrl+t")
+	h.typeText("invoices")
+	h.press("esc")
+	h.golden("add_topic_cancelled")
+}
+
+func TestResetOffsetPrompt(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("tab", "down", "tab")
+	h.send(ConsumerSelectedMsg{"billing", "billing-1", "Stable", []ConsumerTopicPartition{{"orders", "42", 0}}})
+	h.press("ctrl+o")
+	h.golden("reset_offset_prompt")
+}
+
+func TestError(t *testing.T) {
+	cluster := testCluster(t)
+	h := newHarness(t, testConfig(), cluster)
+
+	cluster.SetUnreachable(errors.New("Broker: Transport failure"))
+	h.send(TopicsSelectedMsg{})
+	h.golden("error")
+
+	cluster.SetUnreachable(nil)
+	h.press("enter")
+	h.golden("error_dismissed")
+}
+
+func TestConnectionPrompt(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("e")
+	h.golden("edit_connection")
+
+	h.press("esc")
+	h.press("c")
+	h.golden("clone_connection")
+
+	h.press("esc")
+	h.golden("connection_prompt_cancelled")
+}
+
+func TestDeleteConnectionConfirm(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("down", "x")
+	h.golden("delete_connection")
+
+	h.press("n")
+	h.golden("delete_connection_cancelled")
+}
+
+func TestSwitchConnection(t *testing.T) {
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("tab", "down", "tab", "tab", "tab")
+	h.press("down")
+	h.golden("switched_connection")
+
+	h.press("up")
+	h.golden("switched_back")
+}
+
+func TestSwitchConnectionFailed(t *testing.T) {
+	cluster := testCluster(t)
+	h := startHarness(t, cluster, &model{
+		logger: log.New(io.Discard, "", 0),
+		connect: func(conn Connection, logger *log.Logger) (KafkaBackend, error) {
+			if conn.Name == "staging" {
+				return nil, errors.New("Broker: Transport failure")
+			}
+			return cluster.Connect(conn, logger)
+		},
+		config:     testConfig(),
+		configPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+
+	h.press("tab", "down", "tab", "tab", "tab")
+	if len(h.model.resultComponent.Rows()) == 0 {
+		t.Fatal("Expected the topics of local")
+	}
+	h.press("down")
+	h.golden("switch_connection_failed")
+	if h.model.backend != nil || len(h.model.resultComponent.Rows()) > 0 {
+		t.Error("Expected nothing of local to be shown under the name of staging")
+	}
+}
+
+func TestMessages(t *testing.T) {
+	cluster := testCluster(t)
+	h := newHarness(t, testConfig(), cluster)
+
+	h.press("tab", "tab", "m")
+	h.golden("messages")
+
+	produce(t, cluster, 2, "order-2", "line one\nline two")
+	h.await(RecordsMsg{})
+	h.golden("messages_streamed")
+
+	h.press("enter")
+	h.golden("record")
+
+	h.press("esc")
+	h.press("esc")
+	h.golden("messages_closed")
+}
+
+func TestMessageFormats(t *testing.T) {
+	cluster := testCluster(t)
+	produce(t, cluster, 1, "order-2", `{"id":2,"status":"paid"}`)
+	produce(t, cluster, 2, "order-3", "not json")
+	config := testConfig()
+	path := filepath.Join(t.TempDir(), "config.json")
+	for i := range config.Connections {
+		config.Connections[i].Source.Path = path
+	}
+	if err := WriteConfig(path, config); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness(t, config, cluster)
+
+	h.press("tab", "tab", "m", "v")
+	h.golden("deserializer_json")
+
+	h.press("enter")
+	h.golden("deserializer_json_record")
+
+	h.press("esc", "v", "K", "K", "K")
+	h.golden("deserializer_hex")
+
+	saved, err := ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TopicSettings{Key: &Serde{Format: FormatBase64}, Value: &Serde{Format: FormatHex}}
+	if got := saved.Connections[0].Topics["orders"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Saved settings %+v, want %+v", got, want)
+	}
+
+	h.press("esc", "m")
+	h.golden("deserializer_reopened")
+}
+
+func TestProtobufMessages(t *testing.T) {
+	cluster := testCluster(t)
+	produce(t, cluster, 1, "order-2", string(testOrderMessage()))
+	produce(t, cluster, 2, "order-3", "\x08")
+	dir := t.TempDir()
+	for name, source := range map[string]string{"common.proto": testCommonProto, "order.proto": testOrderProto} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := testConfig()
+	config.Connections[0].Source.Path = filepath.Join(dir, "config.json")
+	config.Connections[0].Topics = map[string]TopicSettings{
+		"orders": {Value: &Serde{Format: FormatProtobuf, Schema: ".", Message: "shop.Order"}},
+	}
+	h := newHarness(t, config, cluster)
+
+	h.press("tab", "tab", "m")
+	h.golden("protobuf_messages")
+
+	h.press("down", "down", "enter")
+	h.golden("protobuf_record_error")
+}
+
+func TestRecordHeaders(t *testing.T) {
+	cluster := testCluster(t)
+	produce(t, cluster, 1, "order-2", "paid", kafka.Header{Key: "trace-id", Value: []byte("abc")}, kafka.Header{Key: "source", Value: []byte("api")})
+	produce(t, cluster, 2, "order-3", "shipped", kafka.Header{Key: "trace-id", Value: []byte("def")})
+	config := testConfig()
+	path := filepath.Join(t.TempDir(), "config.json")
+	for i := range config.Connections {
+		config.Connections[i].Source.Path = path
+	}
+	if err := WriteConfig(path, config); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness(t, config, cluster)
+
+	h.press("tab", "tab", "m", "H")
+	h.typeText("trace-id, source")
+	h.press("enter")
+	h.golden("headers_columns")
+
+	saved, err := ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := saved.Connections[0].Topics["orders"].HeaderColumns; !reflect.DeepEqual(got, []string{"trace-id", "source"}) {
+		t.Errorf("Saved header columns %v, want [trace-id source]", got)
+	}
+
+	h.press("down", "enter")
+	h.golden("headers_record")
+
+	h.press("esc", "f")
+	h.typeText("header:trace-id=/[/")
+	h.press("enter")
+	h.golden("headers_filter_invalid")
+
+	h.press("ctrl+u")
+	h.typeText("header:trace-id=a")
+	h.press("enter")
+	h.golden("headers_filtered")
+
+	h.press("esc", "m")
+	h.golden("headers_reopened")
+}
+
+func TestTail(t *testing.T) {
+	cluster := testCluster(t)
+	config := testConfig()
+	config.Connections[0].TailSize = 2
+	h := newHarness(t, config, cluster)
+
+	// the clock moves a tick forward before every tick fires
+	var mu sync.Mutex
+	now := testTime
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	tick := func() {
+		mu.Lock()
+		now = now.Add(tailTickInterval)
+		mu.Unlock()
+		h.await(TailMsg{})
+	}
+
+	h.press("tab", "tab", "m")
+	h.model.messages.now = clock
+	h.press("F")
+	tick()
+	h.golden("tail_started")
+
+	produce(t, cluster, 1, "order-2", "paid")
+	produce(t, cluster, 2, "order-3", "shipped")
+	tick()
+	h.golden("tail_following")
+
+	h.press(" ")
+	produce(t, cluster, 0, "order-4", "created")
+	tick()
+	h.golden("tail_paused")
+
+	h.press(" ")
+	h.golden("tail_resumed")
+
+	h.press("b")
+	h.golden("tail_left")
+}
+
+func TestBrowseSize(t *testing.T) {
+	cluster := testCluster(t)
+	produce(t, cluster, 1, "order-2", "paid")
+	produce(t, cluster, 2, "order-3", "shipped")
+	config := testConfig()
+	config.Connections[0].BrowseSize = 2
+	h := newHarness(t, config, cluster)
+
+	h.press("tab", "tab", "m")
+	h.golden("browse_size")
+}
+
+func TestSchemaRegistryMessages(t *testing.T) {
+	server, _ := testRegistry(t)
+	cluster := testCluster(t)
+	produce(t, cluster, 1, "order-2", string(wireFormat(1, []byte{0x54, 0x08}, []byte("paid"))))
+	produce(t, cluster, 2, "order-3", string(wireFormat(9, []byte("?"))))
+	config := testConfig()
+	config.Connections[0].SchemaRegistry = &SchemaRegistryConfig{URL: server.URL, Username: "djafka", Password: "secret"}
+	h := newHarness(t, config, cluster)
+
+	h.press("tab", "tab", "m")
+	h.golden("registry_messages")
+
+	h.press("down", "enter")
+	h.golden("registry_record")
+}
+
+func TestSchemas(t *testing.T) {
+	server, _ := testRegistry(t)
+	dir := t.TempDir()
+	compatible := filepath.Join(dir, "order.avsc")
+	incompatible := filepath.Join(dir, "order-v2.avsc")
+	for path, schema := range map[string]string{compatible: testAvroSchema, incompatible: testAvroSchemaV2} {
+		if err := os.WriteFile(path, []byte(schema), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := newHarness(t, testConfig(), testCluster(t))
+
+	h.press("tab", "down", "down")
+	h.golden("schemas_unconfigured")
+
+	config := testConfig()
+	config.Connections[0].SchemaRegistry = &SchemaRegistryConfig{URL: server.URL, Username: "djafka", Password: "secret"}
+	h = newHarness(t, config, testCluster(t))
+
+	h.press("tab", "down", "down", "tab", "down", "down")
+	h.golden("schemas")
+
+	h.press("enter")
+	h.golden("schema_latest")
+
+	h.press("d")
+	h.golden("schema_diff")
+
+	h.press("c")
+	h.typeText(incompatible)
+	h.press("enter")
+	h.golden("schema_incompatible")
+
+	h.press("c")
+	h.typeText(compatible)
+	h.press("enter", "r")
+	h.golden("schema_registered")
+
+	h.press("esc")
+	h.golden("schemas_closed")
+}
+
+func TestSeek(t *testing.T) {
+	cluster := testCluster(t)
+	for i := 1; i <= 3; i++ {
+		produceAt(t, cluster, testTime.Add(time.Duration(i)*time.Hour), 0, fmt.Sprintf("order-%d", i+1), "updated")
+		produceAt(t, cluster, testTime.Add(time.Duration(i)*time.Hour), 1, fmt.Sprintf("order-%d", i+4), "created")
+	}
+	h := newHarness(t, testConfig(), cluster)
+
+	h.press("tab", "tab", "m")
+	h.golden("seek_earliest")
+
+	h.press("e")
+	h.golden("seek_latest")
+
+	h.press("n")
+	h.typeText("1")
+	h.press("enter")
+	h.golden("seek_last_n")
+
+	h.press("o")
+	h.typeText("0:2,1:x")
+	h.press("enter")
+	h.golden("seek_invalid")
+
+	h.press("backspace")
+	h.typeText("1")
+	h.press("enter")
+	h.golden("seek_offsets")
+
+	h.press("o")
+	h.typeText("0:3")
+	h.press("enter")
+	h.golden("seek_offsets_of_partition")
+
+	h.press("o")
+	h.typeText("5:0")
+	h.press("enter")
+	h.golden("seek_unknown_partition")
+
+	h.press("t")
+	h.typeText("2023-06-01 14:00:00")
+	h.press("enter")
+	h.golden("seek_timestamp")
+
+	h.press("o")
+	h.press("esc")
+	h.golden("seek_cancelled")
+}
+
+func TestProduce(t *testing.T) {
+	cluster := testCluster(t)
+	h := newHarness(t, testConfig(), cluster)
+
+	h.press("tab", "tab", "p")
+	h.golden("produce_prompt")
+
+	h.typeText("order-9")
+	h.press("tab")
+	h.typeText("7")
+	h.press("ctrl+s")
+	h.golden("produce_invalid_partition")
+
+	h.press("backspace")
+	h.typeText("1")
+	h.press("tab")
+	h.typeText("source=test,trace=abc")
+	h.press("tab")
+	h.typeText(`{"id":9,`)
+	h.press("enter")
+	h.typeText(`"status":"paid"}`)
+	h.press("ctrl+s")
+	h.golden("produce_delivered")
+
+	h.press("esc", "m", "n")
+	h.typeText("1")
+	h.press("enter", "down", "enter")
+	h.golden("produced_record")
+}
+
+func TestBulkProduce(t *testing.T) {
+	cluster := testCluster(t)
+	h := newHarness(t, testConfig(), cluster)
+
+	h.press("tab", "tab", "f")
+	h.typeText("testdata/missing.jsonl")
+	h.press("enter", "enter", "enter")
+	h.golden("bulk_produce_missing_file")
+
+	h.press("tab", "ctrl+u")
+	h.typeText("testdata/orders.jsonl")
+	h.press("enter", "enter", "enter")
+	h.golden("bulk_produce_done")
+
+	h.press("esc", "m", "n")
+	h.typeText("2")
+	h.press("enter")
+	h.golden("bulk_produced_records")
+}
+
+func TestExport(t *testing.T) {
+	cluster := testCluster(t)
+	produce(t, cluster, 0, "order-2", `{"id":2}`)
+	produce(t, cluster, 1, "order-3", `{"id":3}`)
+	h := newHarness(t, testConfig(), cluster)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	h.press("tab", "tab", "s")
+	h.typeText("orders.jsonl")
+	h.press("enter", "enter")
+	h.typeText("0")
+	h.press("enter")
+	h.typeText("yesterday")
+	h.press("enter", "enter")
+	h.golden("export_invalid_range")
+
+	h.press("shift+tab", "ctrl+u")
+	h.typeText("1")
+	h.press("enter", "enter")
+	h.golden("export_done")
+
+	h.press("enter")
+	h.golden("export_exists")
+
+	exported, err := os.ReadFile("orders.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"topic":"orders","partition":0,"offset":1,"timestamp":1685622600000,"key":"order-2","value":{"id":2},"headers":[]}` + "\n"
+	if string(exported) != want {
+		t.Errorf("expected %s, got %s", want, exported)
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	cluster := testCluster(t)
+	produce(t, cluster, 1, "order-2", `{"id":2,"status":"paid"}`, kafka.Header{Key: "trace", Value: []byte("t-2")})
+	produce(t, cluster, 2, "order-3", `{"id":3,"status":"created"}`)
+	produce(t, cluster, 2, "order-4", `{"id":4,"status":"paid"}`)
+	h := newHarness(t, testConfig(), cluster)
+
+	h.press("tab", "tab", "m", "/")
+	h.typeText("$.id ==")
+	h.press("enter")
+	h.golden("search_invalid")
+
+	h.typeText(` 4 || true`)
+	h.press("ctrl+u")
+	h.typeText(`$.status == "paid"`)
+	h.press("tab", "tab")
+	h.golden("search_form")
+
+	h.press("enter")
+	h.golden("search_matches")
+
+	h.press("/", "ctrl+u")
+	h.typeText("header:trace=t-2")
+	h.press("enter", "enter")
+	h.golden("search_match_record")
+
+	h.press("esc", "esc")
+	h.golden("search_closed")
+}
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	"github.com/scyhhe/djafka/internal/djafka"
 )
 
 func main() {
+	configPath := flag.String("config", "", "path to the config file (default: $DJAFKA_CONFIG, $XDG_CONFIG_HOME/djafka/config.json and ./config.json)")
+	flag.Parse()
+
+	if flag.Arg(0) == "produce" {
+		os.Exit(djafka.RunProduce(*configPath, flag.Args()[1:]))
+	}
+
 	// service, err := djafka.NewService(djafka.Connection{Name: "test", BootstrapServer: "localhost"})
 	// if err != nil {
 	// 	panic(err)
@@ -61,5 +71,5 @@ func main() {
 	// 	messageChan <- "STOP"
 	// }
 
-	djafka.Run()
+	djafka.Run(*configPath)
 }